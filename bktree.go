@@ -0,0 +1,143 @@
+package main
+
+import "context"
+
+// damerauLevenshtein computes the Damerau-Levenshtein edit distance between
+// a and b: insertions, deletions, substitutions, and adjacent-rune
+// transpositions each cost 1. Operates on runes so a folded Polish name
+// still counts as one edit per letter.
+func damerauLevenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	la, lb := len(ar), len(br)
+
+	d := make([][]int, la+1)
+	for i := range d {
+		d[i] = make([]int, lb+1)
+		d[i][0] = i
+	}
+	for j := 0; j <= lb; j++ {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= la; i++ {
+		for j := 1; j <= lb; j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+cost)
+			}
+		}
+	}
+	return d[la][lb]
+}
+
+// bkNode is one node of a bkTree, keyed by edit distance to its parent.
+// idxs holds every record index whose folded name is exactly node.folded:
+// distinct records routinely fold to the same name (e.g. the same street
+// name in two towns), and they all belong at this one node rather than
+// being forced apart with a fake non-zero edge distance.
+type bkNode struct {
+	folded   string
+	idxs     []int
+	children map[int]*bkNode
+}
+
+// bkTree is a BK-tree (Burkhard-Keller tree) over diacritic-folded record
+// names, indexed by Damerau-Levenshtein distance. It supports
+// typo-tolerant lookups ("Chpina" finding "Chopina") without comparing the
+// query against every record.
+type bkTree struct {
+	root *bkNode
+}
+
+func newBKTree() *bkTree {
+	return &bkTree{}
+}
+
+// insert adds idx under folded, the diacritic-folded, lowercased name of the
+// record at that index.
+func (t *bkTree) insert(folded string, idx int) {
+	if t.root == nil {
+		t.root = &bkNode{folded: folded, idxs: []int{idx}, children: make(map[int]*bkNode)}
+		return
+	}
+
+	node := t.root
+	for {
+		d := damerauLevenshtein(node.folded, folded)
+		if d == 0 {
+			// Same folded name as this node (e.g. the same street name in
+			// two towns): it belongs here, not at a manufactured edge
+			// distance, which would break the BK-tree invariant that a
+			// child's edge equals its real distance from the parent.
+			node.idxs = append(node.idxs, idx)
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			node.children[d] = &bkNode{folded: folded, idxs: []int{idx}, children: make(map[int]*bkNode)}
+			return
+		}
+		node = child
+	}
+}
+
+// bkMatch is one fuzzy hit: the record index and its edit distance from the
+// query.
+type bkMatch struct {
+	idx  int
+	dist int
+}
+
+// bkSearchBatch is how often search rechecks ctx for cancellation while
+// walking the tree.
+const bkSearchBatch = 256
+
+// search walks the tree from the root, pruning with the triangle
+// inequality: at a node whose distance to query is d, only children whose
+// edge distance falls in [d-maxDist, d+maxDist] can contain a match within
+// maxDist of query. It stops early if ctx is cancelled mid-walk, returning
+// whatever matches it already found along with partial=true.
+func (t *bkTree) search(ctx context.Context, query string, maxDist int) (out []bkMatch, partial bool) {
+	if t.root == nil {
+		return nil, false
+	}
+
+	visited := 0
+	cancelled := false
+	var walk func(n *bkNode)
+	walk = func(n *bkNode) {
+		if cancelled {
+			return
+		}
+		visited++
+		if visited%bkSearchBatch == 0 {
+			select {
+			case <-ctx.Done():
+				cancelled = true
+				return
+			default:
+			}
+		}
+
+		d := damerauLevenshtein(n.folded, query)
+		if d <= maxDist {
+			for _, idx := range n.idxs {
+				out = append(out, bkMatch{idx: idx, dist: d})
+			}
+		}
+		for edge, child := range n.children {
+			if edge >= d-maxDist && edge <= d+maxDist {
+				walk(child)
+				if cancelled {
+					return
+				}
+			}
+		}
+	}
+	walk(t.root)
+	return out, cancelled
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func bkDepth(n *bkNode) int {
+	if n == nil {
+		return 0
+	}
+	max := 0
+	for _, c := range n.children {
+		if d := bkDepth(c); d > max {
+			max = d
+		}
+	}
+	return max + 1
+}
+
+func TestBKTreeDuplicatesStayAtOneNode(t *testing.T) {
+	bk := newBKTree()
+	for i := 0; i < 3000; i++ {
+		bk.insert(fmt.Sprintf("unique%d", i), i)
+	}
+	for i := 0; i < 5000; i++ {
+		bk.insert("duplicate", 3000+i)
+	}
+
+	if depth := bkDepth(bk.root); depth > 20 {
+		t.Fatalf("expected shallow tree, got max depth %d", depth)
+	}
+
+	matches, partial := bk.search(context.Background(), "duplicate", 0)
+	if partial {
+		t.Fatalf("expected search to complete")
+	}
+	if len(matches) != 5000 {
+		t.Fatalf("expected 5000 exact matches for the duplicate name, got %d", len(matches))
+	}
+}
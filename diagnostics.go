@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// maxTrackedParseErrors bounds the parseErrorRing so a dump with millions
+// of malformed integer columns can't grow AutocompleteService's memory
+// usage unboundedly; only the most recent errors matter for diagnosis.
+const maxTrackedParseErrors = 200
+
+// ParseError is one field that failed to parse as an integer while loading
+// a street or city row. The row itself still loads (with that field left
+// at its zero value); ParseError exists purely for operators to find out
+// why, via AutocompleteService.LastLoadErrors, without re-running under a
+// debugger.
+type ParseError struct {
+	Line  int
+	Field string
+	Raw   string
+	Err   error
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d field %s (%q): %v", e.Line, e.Field, e.Raw, e.Err)
+}
+
+// parseErrorRing is a small, fixed-capacity, thread-safe buffer of the most
+// recent ParseErrors. It's used both inside the concurrent semicolon/CSV
+// sources (whose parser workers append to it from multiple goroutines) and
+// on AutocompleteService itself (LastLoadErrors merges each source's ring
+// into it once the source is drained).
+type parseErrorRing struct {
+	mu   sync.Mutex
+	errs []ParseError
+}
+
+func (r *parseErrorRing) add(e ParseError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, e)
+	if len(r.errs) > maxTrackedParseErrors {
+		r.errs = r.errs[len(r.errs)-maxTrackedParseErrors:]
+	}
+}
+
+func (r *parseErrorRing) snapshot() []ParseError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]ParseError, len(r.errs))
+	copy(out, r.errs)
+	return out
+}
+
+// LastLoadErrors returns the most recent per-field parse errors captured
+// across every Load/LoadCities call so far (bounded to the last
+// maxTrackedParseErrors), newest last. See the /admin/load-errors endpoint
+// for the HTTP-exposed form.
+func (s *AutocompleteService) LastLoadErrors() []ParseError {
+	return s.lastLoadErrors.snapshot()
+}
+
+// averageStreetRecordBytes and averageCityRecordBytes are rough estimates
+// of one semicolon-delimited row's on-disk size (fields + separators +
+// newline), used only to size a preallocation; parsing still works at any
+// row length.
+const (
+	averageStreetRecordBytes = 50
+	averageCityRecordBytes   = 45
+
+	// maxPreallocRecords caps the guess regardless of input size, so a
+	// corrupt or unexpectedly huge source can't make Load allocate a
+	// wildly oversized slice up front (the archive/zip lesson: never trust
+	// an input-derived size enough to preallocate without a ceiling).
+	maxPreallocRecords = 1_000_000
+)
+
+// estimateRecordCapacity turns a byte-length hint into a slice capacity
+// guess: dataLen/avgRecordBytes, capped at maxPreallocRecords. Returns 0
+// (no preallocation) if dataLen is unknown.
+func estimateRecordCapacity(dataLen, avgRecordBytes int) int {
+	if dataLen <= 0 || avgRecordBytes <= 0 {
+		return 0
+	}
+	n := dataLen / avgRecordBytes
+	if n > maxPreallocRecords {
+		return maxPreallocRecords
+	}
+	return n
+}
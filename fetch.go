@@ -0,0 +1,181 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// defaultFetchCacheDir is where downloaded TERYT payloads are cached on
+// disk, keyed by a hash of their source URL, when the service hasn't been
+// given one of its own via SetFetchCacheDir.
+const defaultFetchCacheDir = "data/.fetch-cache"
+
+// fetchMeta is the on-disk sidecar recording the revalidation headers for
+// one cached payload, alongside its <hash>.bin.
+type fetchMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// SetHTTPClient overrides the *http.Client used by LoadStreetsFromURL and
+// LoadCitiesFromURL, for pointing at a mock server in tests or adding
+// custom transport-level behavior (timeouts, proxies). The zero value
+// (nil) falls back to http.DefaultClient.
+func (s *AutocompleteService) SetHTTPClient(client *http.Client) {
+	s.httpClient = client
+}
+
+// SetFetchCacheDir overrides the directory LoadStreetsFromURL and
+// LoadCitiesFromURL use to cache downloaded payloads. The zero value ("")
+// falls back to defaultFetchCacheDir.
+func (s *AutocompleteService) SetFetchCacheDir(dir string) {
+	s.fetchCacheDir = dir
+}
+
+// fetchURL downloads url into memory, revalidating any cached copy at
+// cacheDir (keyed by sha256(url)) with If-None-Match/If-Modified-Since
+// before replacing it. A non-2xx, non-304 response is returned as an error
+// without retrying: a failed fetch should surface immediately rather than
+// silently serve stale data.
+func fetchURL(ctx context.Context, client *http.Client, cacheDir, url string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if cacheDir == "" {
+		cacheDir = defaultFetchCacheDir
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(sum[:])
+	dataPath := filepath.Join(cacheDir, key+".bin")
+	metaPath := filepath.Join(cacheDir, key+".json")
+
+	cached, cacheErr := os.ReadFile(dataPath)
+	var meta fetchMeta
+	if cacheErr == nil {
+		if raw, err := os.ReadFile(metaPath); err == nil {
+			_ = json.Unmarshal(raw, &meta)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request for %s: %w", url, err)
+	}
+	req.Header.Set("Accept", "application/zip, text/csv, */*")
+	if cacheErr == nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cacheErr == nil {
+		return cached, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetch %s: unexpected status %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body for %s: %w", url, err)
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err == nil {
+		_ = os.WriteFile(dataPath, body, 0o644)
+		meta = fetchMeta{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		if raw, err := json.Marshal(meta); err == nil {
+			_ = os.WriteFile(metaPath, raw, 0o644)
+		}
+	}
+
+	return body, nil
+}
+
+// unpackCSV returns payload unchanged if it looks like plain text, or the
+// bytes of its first .csv entry if it's a ZIP archive (TERYT distributes
+// ULIC/SIMC as zipped CSVs).
+func unpackCSV(payload []byte) ([]byte, error) {
+	if len(payload) < 2 || payload[0] != 'P' || payload[1] != 'K' {
+		return payload, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(payload), int64(len(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("open zip payload: %w", err)
+	}
+	for _, f := range zr.File {
+		if filepath.Ext(f.Name) == ".csv" {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("open %s in zip: %w", f.Name, err)
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+	}
+	return nil, fmt.Errorf("no .csv entry found in zip payload")
+}
+
+// LoadStreetsFromURL downloads the ULIC CSV (raw or zipped) from url and
+// loads it the same way LoadCSV does, via the service's configured
+// *http.Client and fetch cache directory (see SetHTTPClient,
+// SetFetchCacheDir).
+func (s *AutocompleteService) LoadStreetsFromURL(ctx context.Context, url string) error {
+	payload, err := fetchURL(ctx, s.httpClient, s.fetchCacheDir, url)
+	if err != nil {
+		return fmt.Errorf("fetch streets from %s: %w", url, err)
+	}
+	data, err := unpackCSV(payload)
+	if err != nil {
+		return fmt.Errorf("unpack streets payload from %s: %w", url, err)
+	}
+
+	report, err := s.Load(ctx, NewSemicolonStreetSource(bytes.NewReader(data), DefaultLoaderOptions()))
+	if err != nil {
+		return fmt.Errorf("load streets from %s: %w", url, err)
+	}
+	log.Printf("Loaded %d street records from %s (skipped %d malformed records)", report.Loaded, url, report.Skipped)
+	return nil
+}
+
+// LoadCitiesFromURL downloads the SIMC CSV (raw or zipped) from url and
+// loads it the same way LoadCitiesCSV does, via the service's configured
+// *http.Client and fetch cache directory (see SetHTTPClient,
+// SetFetchCacheDir).
+func (s *AutocompleteService) LoadCitiesFromURL(ctx context.Context, url string) error {
+	payload, err := fetchURL(ctx, s.httpClient, s.fetchCacheDir, url)
+	if err != nil {
+		return fmt.Errorf("fetch cities from %s: %w", url, err)
+	}
+	data, err := unpackCSV(payload)
+	if err != nil {
+		return fmt.Errorf("unpack cities payload from %s: %w", url, err)
+	}
+
+	report, err := s.LoadCities(ctx, NewSemicolonCitySource(bytes.NewReader(data), DefaultLoaderOptions()))
+	if err != nil {
+		return fmt.Errorf("load cities from %s: %w", url, err)
+	}
+	log.Printf("Loaded %d city records from %s (skipped %d malformed records)", report.Loaded, url, report.Skipped)
+	return nil
+}
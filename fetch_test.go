@@ -0,0 +1,141 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchURLCachesAndRevalidatesWith304(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("WOJ;POW\n02;01\n"))
+	}))
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+
+	body, err := fetchURL(context.Background(), srv.Client(), cacheDir, srv.URL)
+	if err != nil {
+		t.Fatalf("first fetchURL: %v", err)
+	}
+	if string(body) != "WOJ;POW\n02;01\n" {
+		t.Fatalf("unexpected body: %q", body)
+	}
+
+	body, err = fetchURL(context.Background(), srv.Client(), cacheDir, srv.URL)
+	if err != nil {
+		t.Fatalf("second fetchURL: %v", err)
+	}
+	if string(body) != "WOJ;POW\n02;01\n" {
+		t.Fatalf("unexpected body on 304 revalidation: %q", body)
+	}
+	if hits != 2 {
+		t.Fatalf("expected 2 requests (initial + revalidation), got %d", hits)
+	}
+}
+
+func TestFetchURLNon2xxIsAnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if _, err := fetchURL(context.Background(), srv.Client(), t.TempDir(), srv.URL); err == nil {
+		t.Fatal("expected a non-2xx response to return an error")
+	}
+}
+
+func TestUnpackCSVPassesThroughPlainText(t *testing.T) {
+	data, err := unpackCSV([]byte("WOJ;POW\n02;01\n"))
+	if err != nil {
+		t.Fatalf("unpackCSV: %v", err)
+	}
+	if string(data) != "WOJ;POW\n02;01\n" {
+		t.Fatalf("expected plain text unchanged, got %q", data)
+	}
+}
+
+func TestUnpackCSVExtractsFromZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("ULIC.csv")
+	if err != nil {
+		t.Fatalf("zip create: %v", err)
+	}
+	if _, err := f.Write([]byte("WOJ;POW\n02;01\n")); err != nil {
+		t.Fatalf("zip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+
+	data, err := unpackCSV(buf.Bytes())
+	if err != nil {
+		t.Fatalf("unpackCSV: %v", err)
+	}
+	if string(data) != "WOJ;POW\n02;01\n" {
+		t.Fatalf("expected the zip's .csv entry, got %q", data)
+	}
+}
+
+func TestUnpackCSVZipWithNoCSVEntryErrors(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("README.txt")
+	if err != nil {
+		t.Fatalf("zip create: %v", err)
+	}
+	if _, err := f.Write([]byte("not a csv")); err != nil {
+		t.Fatalf("zip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+
+	if _, err := unpackCSV(buf.Bytes()); err == nil {
+		t.Fatal("expected an error when the zip has no .csv entry")
+	}
+}
+
+func TestLoadStreetsFromURLZippedCSV(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("ULIC.csv")
+	if err != nil {
+		t.Fatalf("zip create: %v", err)
+	}
+	csv := "WOJ;POW;GMI;RODZ_GMI;SYM;SYM_UL;CECHA;NAZWA_1;NAZWA_2;\n" +
+		"02;01;01;1;100;1;ul.;Chopina;;\n"
+	if _, err := f.Write([]byte(csv)); err != nil {
+		t.Fatalf("zip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	s := NewAutocompleteService()
+	s.SetHTTPClient(srv.Client())
+	s.SetFetchCacheDir(t.TempDir())
+
+	if err := s.LoadStreetsFromURL(context.Background(), srv.URL); err != nil {
+		t.Fatalf("LoadStreetsFromURL: %v", err)
+	}
+	if len(s.streets) != 1 || s.streets[0].NAZWA1 != "Chopina" {
+		t.Fatalf("expected 1 street named Chopina, got %+v", s.streets)
+	}
+}
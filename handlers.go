@@ -1,14 +1,44 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
-
+	"strings"
 	"time"
+
+	"github.com/slav123/teryt-autocomplete/query"
+	"github.com/slav123/teryt-autocomplete/transit"
 )
 
+// parseSearchOptions builds a SearchOptions from the request's mode, fold,
+// offset, fuzzy, and max_dist query parameters, defaulting to
+// DefaultSearchOptions(limit).
+func parseSearchOptions(r *http.Request, limit int) SearchOptions {
+	opts := DefaultSearchOptions(limit)
+
+	if r.URL.Query().Get("mode") == "prefix" {
+		opts.Mode = ModePrefix
+	}
+	if fold := r.URL.Query().Get("fold"); fold != "" {
+		opts.FoldDiacritics = fold != "0"
+	}
+	if offset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && offset > 0 {
+		opts.Offset = offset
+	}
+	if r.URL.Query().Get("fuzzy") == "1" {
+		opts.Fuzzy = true
+		opts.MaxDist = 2
+		if maxDist, err := strconv.Atoi(r.URL.Query().Get("max_dist")); err == nil && maxDist > 0 {
+			opts.MaxDist = maxDist
+		}
+	}
+
+	return opts
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
@@ -21,6 +51,9 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 func streetsHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
@@ -30,8 +63,11 @@ func streetsHandler(w http.ResponseWriter, r *http.Request) {
 	// Default limit
 	limit := 10
 
+	opts := parseSearchOptions(r, limit)
+
 	// Search
-	results := service.SearchStreets(query, limit)
+	results, partial := service.SearchWithOptions(ctx, query, opts)
+	deadlineExceeded := ctx.Err() == context.DeadlineExceeded
 
 	// Check if this is an HTMX request
 	isHTMX := r.Header.Get("HX-Request") == "true"
@@ -69,6 +105,9 @@ func streetsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Return JSON for regular API requests
 	w.Header().Set("Content-Type", "application/json")
+	if deadlineExceeded {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 
 	if query == "" {
 		json.NewEncoder(w).Encode(AutocompleteResponse{
@@ -81,11 +120,26 @@ func streetsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Build response
+	records := make([]StreetRecord, len(results))
+	var distances []int
+	if opts.Fuzzy {
+		distances = make([]int, len(results))
+	}
+	for i, m := range results {
+		records[i] = m.StreetRecord
+		if opts.Fuzzy {
+			distances[i] = m.Distance
+		}
+	}
+
 	response := AutocompleteResponse{
-		Query:   query,
-		Results: results,
-		Count:   len(results),
-		Time:    time.Since(startTime).String(),
+		Query:            query,
+		Results:          records,
+		Distances:        distances,
+		Count:            len(records),
+		Partial:          partial,
+		DeadlineExceeded: deadlineExceeded,
+		Time:             time.Since(startTime).String(),
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -94,6 +148,9 @@ func streetsHandler(w http.ResponseWriter, r *http.Request) {
 func citiesHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
@@ -108,8 +165,11 @@ func citiesHandler(w http.ResponseWriter, r *http.Request) {
 	// Default limit
 	limit := 10
 
+	opts := parseSearchOptions(r, limit)
+
 	// Search with filters
-	results := service.SearchCities(query, woj, pow, gmi, limit)
+	results, partial := service.SearchCitiesWithOptions(ctx, query, woj, pow, gmi, opts)
+	deadlineExceeded := ctx.Err() == context.DeadlineExceeded
 
 	// Check if this is an HTMX request
 	isHTMX := r.Header.Get("HX-Request") == "true"
@@ -134,7 +194,7 @@ func citiesHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Deduplicate by city name for display
 		seen := make(map[string]bool)
-		uniqueResults := []CityRecord{}
+		uniqueResults := []CityMatch{}
 		for _, result := range results {
 			if !seen[result.NAZWA] {
 				uniqueResults = append(uniqueResults, result)
@@ -157,6 +217,9 @@ func citiesHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Return JSON for regular API requests
 	w.Header().Set("Content-Type", "application/json")
+	if deadlineExceeded {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 
 	// Build filters map for response
 	filters := make(map[string]int)
@@ -171,12 +234,41 @@ func citiesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Build response
+	records := make([]CityRecord, len(results))
+	var distances []int
+	if opts.Fuzzy {
+		distances = make([]int, len(results))
+	}
+	for i, m := range results {
+		records[i] = m.CityRecord
+		if opts.Fuzzy {
+			distances[i] = m.Distance
+		}
+	}
+
+	service.mu.RLock()
+	registry := service.transitRegistry
+	service.mu.RUnlock()
+
+	var transitSummaries []*transit.CitySummary
+	if registry != nil {
+		transitSummaries = make([]*transit.CitySummary, len(records))
+		for i, rec := range records {
+			summary, _ := registry.Lookup(rec.NAZWA, 0)
+			transitSummaries[i] = summary
+		}
+	}
+
 	response := CityAutocompleteResponse{
-		Query:   query,
-		Filters: filters,
-		Results: results,
-		Count:   len(results),
-		Time:    time.Since(startTime).String(),
+		Query:            query,
+		Filters:          filters,
+		Results:          records,
+		Distances:        distances,
+		TransitSummaries: transitSummaries,
+		Count:            len(records),
+		Partial:          partial,
+		DeadlineExceeded: deadlineExceeded,
+		Time:             time.Since(startTime).String(),
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -189,6 +281,9 @@ func citiesHandler(w http.ResponseWriter, r *http.Request) {
 func streetGMIHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
@@ -206,15 +301,147 @@ func streetGMIHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get GMI codes for the exact street name
-	results := service.GetGMIForStreet(streetName)
+	results, partial := service.GetGMIForStreet(ctx, streetName)
+	deadlineExceeded := ctx.Err() == context.DeadlineExceeded
+	if deadlineExceeded {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 
 	// Build response
 	response := map[string]any{
-		"street_name": streetName,
-		"results":     results,
-		"count":       len(results),
-		"time":        time.Since(startTime).String(),
+		"street_name":       streetName,
+		"results":           results,
+		"count":             len(results),
+		"partial":           partial,
+		"deadline_exceeded": deadlineExceeded,
+		"time":              time.Since(startTime).String(),
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// adminLoadErrorsHandler handles GET /admin/load-errors, returning the most
+// recent per-field parse errors captured while loading streets/cities (see
+// AutocompleteService.LastLoadErrors), for diagnosing a malformed TERYT
+// dump without re-running the loader under a debugger.
+func adminLoadErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	errs := service.LastLoadErrors()
+	out := make([]map[string]any, len(errs))
+	for i, e := range errs {
+		out[i] = map[string]any{
+			"line":  e.Line,
+			"field": e.Field,
+			"raw":   e.Raw,
+			"error": e.Err.Error(),
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": out,
+		"count":  len(out),
+	})
+}
+
+// cityStopsHandler handles GET /cities/{sym}/stops, returning the transit
+// stops and lines geocoded against the locality with that SYM code. An
+// optional radius_m query parameter restricts the results to stops within
+// that many meters of the city's stop centroid; omitted or non-positive
+// means no radius filtering. Returns 404 if no such city exists or no
+// transit feed was loaded.
+func cityStopsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.TrimPrefix(r.URL.Path, "/cities/")
+	sym, rest, _ := strings.Cut(path, "/")
+	if rest != "stops" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": "not found"})
+		return
+	}
+
+	symCode, err := strconv.Atoi(sym)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"error": "invalid sym code"})
+		return
+	}
+
+	city, ok := service.cityBySYM(symCode)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": "city not found"})
+		return
+	}
+
+	var radiusMeters float64
+	if raw := r.URL.Query().Get("radius_m"); raw != "" {
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			radiusMeters = v
+		}
+	}
+
+	service.mu.RLock()
+	registry := service.transitRegistry
+	service.mu.RUnlock()
+
+	if registry == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": "no transit feed loaded"})
+		return
+	}
+
+	summary, stops := registry.Lookup(city.NAZWA, radiusMeters)
+	if summary == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": "no transit stops found for this city"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(CityStopsResponse{City: city, Stops: stops, CitySummary: *summary})
+}
+
+// SearchResponse is the JSON response structure for POST /search.
+type SearchResponse struct {
+	Results []JoinedResult `json:"results"`
+	Count   int            `json:"count"`
+	Time    string         `json:"time"`
+	Partial bool           `json:"partial,omitempty"`
+}
+
+// searchHandler evaluates a compound query.Query, posted as JSON, against
+// the loaded street and city data. See the query package for the request
+// body shape.
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]any{"error": "POST required"})
+		return
+	}
+
+	var q query.Query
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"error": "invalid query body: " + err.Error()})
+		return
+	}
+
+	results, partial := service.RunQuery(ctx, q)
+
+	json.NewEncoder(w).Encode(SearchResponse{
+		Results: results,
+		Count:   len(results),
+		Time:    time.Since(startTime).String(),
+		Partial: partial,
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import "strings"
+
+// nameIndex is the startup-built search structure for one record collection:
+// a trie for prefix matches and an inverted index for contains matches, both
+// keyed by diacritic-folded, lowercased names. Record indices refer into the
+// parallel []StreetRecord/[]CityRecord slice the index was built from.
+type nameIndex struct {
+	trie     *nameTrie
+	inverted invertedIndex
+}
+
+func newNameIndex() *nameIndex {
+	return &nameIndex{
+		trie:     newNameTrie(),
+		inverted: newInvertedIndex(),
+	}
+}
+
+// add indexes name (the record's display name) under idx, its position in
+// the source slice.
+func (ix *nameIndex) add(name string, idx int) {
+	folded := foldName(name)
+	ix.trie.insert(folded, idx)
+	ix.inverted.insert(folded, idx)
+}
+
+// SearchMode selects how a query is matched against a nameIndex.
+type SearchMode int
+
+const (
+	// ModeContains matches names that start with or merely contain the
+	// query. This is the historical behavior of Search/SearchCities.
+	ModeContains SearchMode = iota
+	// ModePrefix only matches names that start with the query, which is
+	// cheaper since it skips the inverted-index lookup entirely.
+	ModePrefix
+)
+
+// SearchOptions controls how Search and SearchCities match and page
+// results. The zero value is not a usable default; use DefaultSearchOptions.
+type SearchOptions struct {
+	Mode SearchMode
+	// FoldDiacritics normalizes Polish diacritics out of the query before
+	// matching, so e.g. "lodz" finds "Łódź". Indexed names are always
+	// folded; this only controls whether the query is.
+	FoldDiacritics bool
+	Limit          int
+	Offset         int
+	// Fuzzy additionally matches names within MaxDist typos of the query,
+	// via the BK-tree, and reports the edit distance on each such match.
+	Fuzzy   bool
+	MaxDist int
+}
+
+// DefaultSearchOptions reproduces the historical behavior of Search and
+// SearchCities: contains matching, diacritics folded, no paging, no fuzzy
+// matching.
+func DefaultSearchOptions(limit int) SearchOptions {
+	return SearchOptions{Mode: ModeContains, FoldDiacritics: true, Limit: limit}
+}
+
+// rankOf scores how closely folded (an indexed name) matches query, lowest
+// first: exact match, then prefix, then mere containment. Fuzzy matches are
+// scored separately by the caller since they carry an edit distance.
+func rankOf(folded, query string) int {
+	switch {
+	case folded == query:
+		return 0
+	case strings.HasPrefix(folded, query):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// fuzzyRank scores a BK-tree hit so it always sorts behind every contains
+// match (rank 2) while still ordering closer typos first.
+func fuzzyRank(dist int) int {
+	return 2 + dist
+}
+
+// matchIndex resolves query against ix and returns candidate record indices
+// together with whether each one is a prefix match (rank 0) or only a
+// contains match (rank 1), ordered prefix matches first.
+func matchIndex(ix *nameIndex, query string, mode SearchMode) (idxs []int, prefixRank map[int]bool) {
+	prefixIdxs := ix.trie.prefixSearch(query)
+	prefixRank = make(map[int]bool, len(prefixIdxs))
+	for _, idx := range prefixIdxs {
+		prefixRank[idx] = true
+	}
+	idxs = append(idxs, prefixIdxs...)
+
+	if mode == ModeContains {
+		for _, idx := range ix.inverted.containsSearch(query) {
+			if !prefixRank[idx] {
+				idxs = append(idxs, idx)
+			}
+		}
+	}
+	return idxs, prefixRank
+}
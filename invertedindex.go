@@ -0,0 +1,52 @@
+package main
+
+import "strings"
+
+// invertedIndex answers "contains" queries via a suffix trie: every token
+// indexed contributes each of its suffixes as a trie entry, so containsSearch
+// resolves through a single trie prefixSearch over one suffix instead of
+// scanning every token in the vocabulary.
+type invertedIndex struct {
+	suffixes *nameTrie
+}
+
+func newInvertedIndex() invertedIndex {
+	return invertedIndex{suffixes: newNameTrie()}
+}
+
+// tokenize splits a folded name into its whitespace-separated words.
+func tokenize(folded string) []string {
+	return strings.Fields(folded)
+}
+
+// insert adds idx under every suffix of every token of folded, the
+// diacritic-folded, lowercased name of the record at that index.
+func (ix invertedIndex) insert(folded string, idx int) {
+	for _, tok := range tokenize(folded) {
+		runes := []rune(tok)
+		for i := range runes {
+			ix.suffixes.insert(string(runes[i:]), idx)
+		}
+	}
+}
+
+// containsSearch returns the record indices of every name with a token that
+// contains query as a substring: any suffix of that token starting with
+// query means query occurs somewhere inside it, so this is a single
+// prefixSearch over the suffix trie rather than a full vocabulary scan.
+func (ix invertedIndex) containsSearch(query string) []int {
+	matches := ix.suffixes.prefixSearch(query)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	seen := make(map[int]bool, len(matches))
+	var out []int
+	for _, idx := range matches {
+		if !seen[idx] {
+			seen[idx] = true
+			out = append(out, idx)
+		}
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestInvertedIndexContainsSearchMatchesMidTokenSubstring(t *testing.T) {
+	ix := newInvertedIndex()
+	ix.insert(foldName("Chopina"), 0)
+	ix.insert(foldName("Niepodległości"), 1)
+	ix.insert(foldName("Polna"), 2)
+
+	got := ix.containsSearch(foldName("pod"))
+	if len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected only idx 1 (Niepodległości contains 'pod'), got %v", got)
+	}
+}
+
+func TestInvertedIndexContainsSearchDedupesMultipleTokens(t *testing.T) {
+	ix := newInvertedIndex()
+	ix.insert(foldName("Aleja Aleksandrowska"), 0) // two tokens both contain "ale"
+
+	got := ix.containsSearch(foldName("ale"))
+	if len(got) != 1 || got[0] != 0 {
+		t.Fatalf("expected idx 0 exactly once, got %v", got)
+	}
+}
+
+func TestInvertedIndexContainsSearchNoMatch(t *testing.T) {
+	ix := newInvertedIndex()
+	ix.insert(foldName("Chopina"), 0)
+
+	if got := ix.containsSearch(foldName("zzz")); len(got) != 0 {
+		t.Fatalf("expected no matches, got %v", got)
+	}
+}
+
+func TestInvertedIndexContainsSearchMultipleRecords(t *testing.T) {
+	ix := newInvertedIndex()
+	ix.insert(foldName("Chopina"), 0)
+	ix.insert(foldName("Szopena"), 1)
+	ix.insert(foldName("Polna"), 2)
+
+	got := ix.containsSearch(foldName("opena"))
+	sort.Ints(got)
+	want := []int{1}
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
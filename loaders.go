@@ -1,193 +1,261 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 )
 
-// readLine reads a single line from a strings.Reader
-func readLine(r *strings.Reader) (string, error) {
-	var line strings.Builder
-	for {
-		b, err := r.ReadByte()
-		if err != nil {
-			if line.Len() > 0 {
-				return line.String(), nil
-			}
-			return "", err
-		}
-		if b == '\n' {
-			return line.String(), nil
-		}
-		if b != '\r' { // Skip carriage return
-			line.WriteByte(b)
-		}
-	}
+// LoaderOptions tunes the streaming CSV loader behind NewSemicolonStreetSource
+// and NewSemicolonCitySource.
+type LoaderOptions struct {
+	// Workers is how many goroutines parse rows concurrently. Zero means
+	// runtime.GOMAXPROCS(0).
+	Workers int
+	// BufferSize is the bufio.Scanner buffer size, in bytes. Zero means
+	// bufio.MaxScanTokenSize (64KB), already generous for a single CSV
+	// row; raise it only if a source ever produces longer rows.
+	BufferSize int
+	// StrictMode makes the first malformed row a fatal error returned from
+	// LoadCSVWithOptions/LoadCitiesCSVWithOptions, instead of a skipped
+	// row recorded in LoadReport.Errors.
+	StrictMode bool
 }
 
-// LoadCSV loads the street data from CSV file into memory
-func (s *AutocompleteService) LoadCSV(filename string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// DefaultLoaderOptions returns the options LoadCSV and LoadCitiesCSV use.
+func DefaultLoaderOptions() LoaderOptions {
+	return LoaderOptions{Workers: runtime.GOMAXPROCS(0)}
+}
 
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+func (o LoaderOptions) withDefaults() LoaderOptions {
+	if o.Workers <= 0 {
+		o.Workers = runtime.GOMAXPROCS(0)
 	}
-	defer file.Close()
-
-	// Use manual line-by-line parsing due to CSV data quality issues
-	// The file has unescaped quotes that confuse the standard CSV reader
-	scanner := strings.NewReader("")
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+	if o.BufferSize <= 0 {
+		o.BufferSize = bufio.MaxScanTokenSize
 	}
-	scanner = strings.NewReader(string(data))
+	return o
+}
 
-	s.streets = make([]StreetRecord, 0, 300000)
-	lineNum := 0
-	skipped := 0
+// LineError is one row that failed to parse, keeping its 1-based line
+// number (counting the header) and raw text for diagnostics.
+type LineError struct {
+	Line int
+	Raw  string
+	Err  error
+}
 
-	// Skip header line
-	_, _ = readLine(scanner)
-	lineNum++
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
 
-	for {
-		line, err := readLine(scanner)
-		if err != nil {
-			break
-		}
-		lineNum++
+// LoadReport summarizes one Load/LoadCSVWithOptions/LoadCitiesCSVWithOptions
+// call: how many rows loaded, how many were skipped, and why.
+type LoadReport struct {
+	Loaded  int
+	Skipped int
+	Errors  []LineError
+}
 
-		// Split by semicolon
-		fields := strings.Split(line, ";")
+// splitFields splits a semicolon-delimited CSV line and trims surrounding
+// whitespace and quotes from each field, matching the quirks of the TERYT
+// distribution (which has unescaped quotes that confuse encoding/csv).
+func splitFields(line string) []string {
+	fields := strings.Split(line, ";")
+	for i := range fields {
+		fields[i] = strings.Trim(strings.TrimSpace(fields[i]), "\"")
+	}
+	return fields
+}
 
-		// Validate record has exactly 10 fields
-		if len(fields) != 10 {
-			skipped++
-			continue
-		}
+// fieldIssue is a single strconv.Atoi failure recovered from while parsing
+// one row: the field still defaults to zero (the record as a whole loads),
+// but the issue is worth surfacing via ParseError rather than discarding
+// silently.
+type fieldIssue struct {
+	Field string
+	Raw   string
+	Err   error
+}
 
-		// Clean up fields by removing any quotes
-		for i := range fields {
-			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), "\"")
-		}
+// streetFromFields builds a StreetRecord from one ULIC row's already-split
+// fields (WOJ, POW, GMI, RODZ_GMI, SYM, SYM_UL, CECHA, NAZWA_1, NAZWA_2,
+// trailing). Shared by every StreetSource implementation that starts from
+// positional fields, so the validation and FullName-building rules live in
+// exactly one place. The returned error is fatal (the row is unusable);
+// fieldIssues are non-fatal integer-column failures the record still loads
+// despite (as zero values), reported for diagnostics via ParseError.
+func streetFromFields(fields []string) (StreetRecord, []fieldIssue, error) {
+	if len(fields) != 10 {
+		return StreetRecord{}, nil, fmt.Errorf("expected 10 fields, got %d", len(fields))
+	}
+	if fields[7] == "" { // NAZWA_1 must not be empty
+		return StreetRecord{}, nil, fmt.Errorf("NAZWA_1 is empty")
+	}
 
-		// Validate essential fields are not empty
-		if fields[7] == "" { // NAZWA_1 must not be empty
-			skipped++
-			continue
+	var issues []fieldIssue
+	atoi := func(field, raw string) int {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			issues = append(issues, fieldIssue{Field: field, Raw: raw, Err: err})
 		}
+		return n
+	}
 
-		// Parse integer fields
-		woj, _ := strconv.Atoi(fields[0])
-		pow, _ := strconv.Atoi(fields[1])
-		gmi, _ := strconv.Atoi(fields[2])
-		rodzgmi, _ := strconv.Atoi(fields[3])
-		sym, _ := strconv.Atoi(fields[4])
-		symul, _ := strconv.Atoi(fields[5])
-
-		street := StreetRecord{
-			WOJ:     woj,
-			POW:     pow,
-			GMI:     gmi,
-			RODZGMI: rodzgmi,
-			SYM:     sym,
-			SYMUL:   symul,
-			CECHA:   fields[6],
-			NAZWA1:  fields[7],
-			NAZWA2:  fields[8],
-		}
+	street := StreetRecord{
+		WOJ:     atoi("WOJ", fields[0]),
+		POW:     atoi("POW", fields[1]),
+		GMI:     atoi("GMI", fields[2]),
+		RODZGMI: atoi("RODZ_GMI", fields[3]),
+		SYM:     atoi("SYM", fields[4]),
+		SYMUL:   atoi("SYM_UL", fields[5]),
+		CECHA:   fields[6],
+		NAZWA1:  fields[7],
+		NAZWA2:  fields[8],
+	}
+	if street.NAZWA2 != "" {
+		street.FullName = fmt.Sprintf("%s %s %s", street.CECHA, street.NAZWA1, street.NAZWA2)
+	} else {
+		street.FullName = fmt.Sprintf("%s %s", street.CECHA, street.NAZWA1)
+	}
+	return street, issues, nil
+}
 
-		// Build full name for display
-		if street.NAZWA2 != "" {
-			street.FullName = fmt.Sprintf("%s %s %s", street.CECHA, street.NAZWA1, street.NAZWA2)
-		} else {
-			street.FullName = fmt.Sprintf("%s %s", street.CECHA, street.NAZWA1)
-		}
+// cityFromFields is streetFromFields for one SIMC row's fields (WOJ, POW,
+// GMI, RODZ_GMI, RM, MZ, NAZWA, SYM, SYMPOD, trailing).
+func cityFromFields(fields []string) (CityRecord, []fieldIssue, error) {
+	if len(fields) != 10 {
+		return CityRecord{}, nil, fmt.Errorf("expected 10 fields, got %d", len(fields))
+	}
+	if fields[6] == "" { // NAZWA must not be empty
+		return CityRecord{}, nil, fmt.Errorf("NAZWA is empty")
+	}
 
-		s.streets = append(s.streets, street)
+	var issues []fieldIssue
+	atoi := func(field, raw string) int {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			issues = append(issues, fieldIssue{Field: field, Raw: raw, Err: err})
+		}
+		return n
 	}
 
-	log.Printf("Loaded %d street records from %s (skipped %d malformed records)", len(s.streets), filename, skipped)
-	return nil
+	city := CityRecord{
+		WOJ:     atoi("WOJ", fields[0]),
+		POW:     atoi("POW", fields[1]),
+		GMI:     atoi("GMI", fields[2]),
+		RODZGMI: atoi("RODZ_GMI", fields[3]),
+		RM:      atoi("RM", fields[4]),
+		MZ:      atoi("MZ", fields[5]),
+		NAZWA:   fields[6],
+		SYM:     atoi("SYM", fields[7]),
+		SYMPOD:  atoi("SYMPOD", fields[8]),
+	}
+	return city, issues, nil
 }
 
-// LoadCitiesCSV loads the city/locality data from SIMC CSV file into memory
-func (s *AutocompleteService) LoadCitiesCSV(filename string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// parseStreetLine parses one raw, semicolon-delimited ULIC row.
+func parseStreetLine(line string) (StreetRecord, []fieldIssue, error) {
+	return streetFromFields(splitFields(line))
+}
 
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-	scanner := strings.NewReader(string(data))
+// parseCityLine parses one raw, semicolon-delimited SIMC row.
+func parseCityLine(line string) (CityRecord, []fieldIssue, error) {
+	return cityFromFields(splitFields(line))
+}
 
-	s.cities = make([]CityRecord, 0, 100000)
-	lineNum := 0
-	skipped := 0
+// lineJob is one raw CSV row handed to a parser worker, tagged with its
+// 1-based line number (the header is line 1) for LineError.
+type lineJob struct {
+	num int
+	raw string
+}
 
-	// Skip header line
-	_, _ = readLine(scanner)
-	lineNum++
+// streamLines reads r line by line and fans each one out to a buffered
+// channel, which is returned alongside the *bufio.Scanner so the caller
+// can check scanner.Err() once the channel is drained. The header line is
+// consumed and discarded before streaming begins.
+func streamLines(r io.Reader, opts LoaderOptions) (*bufio.Scanner, <-chan lineJob) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), opts.BufferSize)
 
-	for {
-		line, err := readLine(scanner)
-		if err != nil {
-			break
+	jobs := make(chan lineJob, opts.Workers*4)
+	go func() {
+		defer close(jobs)
+		if !scanner.Scan() {
+			return // empty input, no header to skip and no rows to stream
 		}
-		lineNum++
+		lineNum := 1
+		for scanner.Scan() {
+			lineNum++
+			jobs <- lineJob{num: lineNum, raw: scanner.Text()}
+		}
+	}()
+	return scanner, jobs
+}
 
-		// Split by semicolon
-		fields := strings.Split(line, ";")
+// LoadCSV loads the street data from a CSV file into memory, using
+// DefaultLoaderOptions.
+func (s *AutocompleteService) LoadCSV(filename string) error {
+	_, err := s.LoadCSVWithOptions(filename, DefaultLoaderOptions())
+	return err
+}
 
-		// Validate record has exactly 10 fields
-		if len(fields) != 10 {
-			skipped++
-			continue
-		}
+// LoadCSVWithOptions streams filename through a NewSemicolonStreetSource
+// and s.Load, which merges the results into s.streets under a single
+// write lock and rebuilds the street index. It replaces the whole street
+// set, as LoadCSV always has.
+func (s *AutocompleteService) LoadCSVWithOptions(filename string, opts LoaderOptions) (LoadReport, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return LoadReport{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
 
-		// Clean up fields by removing any quotes
-		for i := range fields {
-			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), "\"")
-		}
+	report, err := s.Load(context.Background(), NewSemicolonStreetSource(file, opts))
+	if err != nil {
+		return report, fmt.Errorf("load %s: %w", filename, err)
+	}
+	if opts.StrictMode && len(report.Errors) > 0 {
+		return report, fmt.Errorf("%d malformed rows in %s (strict mode): %w", len(report.Errors), filename, report.Errors[0])
+	}
 
-		// Validate essential fields are not empty
-		if fields[6] == "" { // NAZWA must not be empty
-			skipped++
-			continue
-		}
+	log.Printf("Loaded %d street records from %s (skipped %d malformed records)", report.Loaded, filename, report.Skipped)
+	return report, nil
+}
 
-		// Parse integer fields
-		woj, _ := strconv.Atoi(fields[0])
-		pow, _ := strconv.Atoi(fields[1])
-		gmi, _ := strconv.Atoi(fields[2])
-		rodzgmi, _ := strconv.Atoi(fields[3])
-		rm, _ := strconv.Atoi(fields[4])
-		mz, _ := strconv.Atoi(fields[5])
-		sym, _ := strconv.Atoi(fields[7])
-		sympod, _ := strconv.Atoi(fields[8])
-
-		city := CityRecord{
-			WOJ:     woj,
-			POW:     pow,
-			GMI:     gmi,
-			RODZGMI: rodzgmi,
-			RM:      rm,
-			MZ:      mz,
-			NAZWA:   fields[6],
-			SYM:     sym,
-			SYMPOD:  sympod,
-		}
+// LoadCitiesCSV loads the city/locality data from a SIMC CSV file into
+// memory, using DefaultLoaderOptions.
+func (s *AutocompleteService) LoadCitiesCSV(filename string) error {
+	_, err := s.LoadCitiesCSVWithOptions(filename, DefaultLoaderOptions())
+	return err
+}
+
+// LoadCitiesCSVWithOptions streams filename through a NewSemicolonCitySource
+// and s.LoadCities, which merges the results into s.cities under a single
+// write lock and rebuilds the city index. It replaces the whole city set,
+// as LoadCitiesCSV always has.
+func (s *AutocompleteService) LoadCitiesCSVWithOptions(filename string, opts LoaderOptions) (LoadReport, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return LoadReport{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
 
-		s.cities = append(s.cities, city)
+	report, err := s.LoadCities(context.Background(), NewSemicolonCitySource(file, opts))
+	if err != nil {
+		return report, fmt.Errorf("load %s: %w", filename, err)
+	}
+	if opts.StrictMode && len(report.Errors) > 0 {
+		return report, fmt.Errorf("%d malformed rows in %s (strict mode): %w", len(report.Errors), filename, report.Errors[0])
 	}
 
-	log.Printf("Loaded %d city records from %s (skipped %d malformed records)", len(s.cities), filename, skipped)
-	return nil
+	log.Printf("Loaded %d city records from %s (skipped %d malformed records)", report.Loaded, filename, report.Skipped)
+	return report, nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// drainStreetSource pulls every record out of src, mirroring what Load does
+// internally, for benchmarking a StreetSource implementation in isolation.
+func drainStreetSource(src StreetSource) (int, error) {
+	n := 0
+	for {
+		_, err := src.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			if _, ok := err.(LineError); ok {
+				continue
+			}
+			return n, err
+		}
+		n++
+	}
+}
+
+// syntheticULIC builds an n-row ULIC CSV (header included) for benchmarking
+// the loader without shipping a 100+ MB fixture in the repo.
+func syntheticULIC(n int) string {
+	var b strings.Builder
+	b.WriteString("WOJ;POW;GMI;RODZ_GMI;SYM;SYM_UL;CECHA;NAZWA_1;NAZWA_2;\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "02;%02d;%02d;1;%d;%d;ul.;Street %d;;\n", i%20, i%10, i, i, i)
+	}
+	return b.String()
+}
+
+func BenchmarkLoadStreetsStream(b *testing.B) {
+	data := syntheticULIC(300000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src := NewSemicolonStreetSource(strings.NewReader(data), DefaultLoaderOptions())
+		n, err := drainStreetSource(src)
+		if err != nil {
+			b.Fatalf("drainStreetSource: %v", err)
+		}
+		if n != 300000 {
+			b.Fatalf("got %d streets, want 300000", n)
+		}
+	}
+}
+
+func BenchmarkLoadStreetsStreamSingleWorker(b *testing.B) {
+	data := syntheticULIC(300000)
+	opts := LoaderOptions{Workers: 1}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src := NewSemicolonStreetSource(strings.NewReader(data), opts)
+		if _, err := drainStreetSource(src); err != nil {
+			b.Fatalf("drainStreetSource: %v", err)
+		}
+	}
+}
@@ -1,16 +1,17 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/slav123/teryt-autocomplete/transit"
 )
 
 // StreetRecord represents a single street entry from the CSV
@@ -44,7 +45,43 @@ type CityRecord struct {
 type AutocompleteService struct {
 	streets []StreetRecord
 	cities  []CityRecord
-	mu      sync.RWMutex
+
+	streetIndex *nameIndex
+	cityIndex   *nameIndex
+	streetBK    *bkTree
+	cityBK      *bkTree
+
+	transitRegistry *transit.Registry
+
+	// httpClient and fetchCacheDir configure LoadStreetsFromURL and
+	// LoadCitiesFromURL; both are nil/"" by default, meaning
+	// http.DefaultClient and defaultFetchCacheDir. See SetHTTPClient and
+	// SetFetchCacheDir.
+	httpClient    *http.Client
+	fetchCacheDir string
+
+	// lastLoadErrors holds the most recent per-field parse errors (e.g. a
+	// malformed WOJ column) captured across Load/LoadCities calls. See
+	// LastLoadErrors.
+	lastLoadErrors parseErrorRing
+
+	mu sync.RWMutex
+}
+
+// StreetMatch pairs a StreetRecord with the edit distance from the query
+// that produced it: 0 for an exact/prefix/contains match, 1+ for a fuzzy
+// (BK-tree) match.
+type StreetMatch struct {
+	StreetRecord
+	Distance int
+}
+
+// CityMatch pairs a CityRecord with the edit distance from the query that
+// produced it: 0 for an exact/prefix/contains match, 1+ for a fuzzy
+// (BK-tree) match.
+type CityMatch struct {
+	CityRecord
+	Distance int
 }
 
 // NewAutocompleteService creates a new autocomplete service
@@ -55,205 +92,158 @@ func NewAutocompleteService() *AutocompleteService {
 	}
 }
 
-// LoadCSV loads the street data from CSV file into memory
-func (s *AutocompleteService) LoadCSV(filename string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+// buildStreetIndex rebuilds the trie/inverted index over s.streets. Callers
+// must hold s.mu for writing.
+func (s *AutocompleteService) buildStreetIndex() {
+	idx := newNameIndex()
+	bk := newBKTree()
+	for i, street := range s.streets {
+		idx.add(street.NAZWA1, i)
+		bk.insert(foldName(street.NAZWA1), i)
 	}
-	defer file.Close()
+	s.streetIndex = idx
+	s.streetBK = bk
+}
 
-	// Use manual line-by-line parsing due to CSV data quality issues
-	// The file has unescaped quotes that confuse the standard CSV reader
-	scanner := strings.NewReader("")
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+// buildCityIndex rebuilds the trie/inverted index and BK-tree over
+// s.cities. Callers must hold s.mu for writing.
+func (s *AutocompleteService) buildCityIndex() {
+	idx := newNameIndex()
+	bk := newBKTree()
+	for i, city := range s.cities {
+		idx.add(city.NAZWA, i)
+		bk.insert(foldName(city.NAZWA), i)
 	}
-	scanner = strings.NewReader(string(data))
-
-	s.streets = make([]StreetRecord, 0, 300000)
-	lineNum := 0
-	skipped := 0
-
-	// Skip header line
-	_, _ = readLine(scanner)
-	lineNum++
-
-	for {
-		line, err := readLine(scanner)
-		if err != nil {
-			break
-		}
-		lineNum++
-
-		// Split by semicolon
-		fields := strings.Split(line, ";")
-
-		// Validate record has exactly 10 fields
-		if len(fields) != 10 {
-			skipped++
-			continue
-		}
-
-		// Clean up fields by removing any quotes
-		for i := range fields {
-			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), "\"")
-		}
-
-		// Validate essential fields are not empty
-		if fields[7] == "" { // NAZWA_1 must not be empty
-			skipped++
-			continue
-		}
+	s.cityIndex = idx
+	s.cityBK = bk
+}
 
-		// Parse integer fields
-		woj, _ := strconv.Atoi(fields[0])
-		pow, _ := strconv.Atoi(fields[1])
-		gmi, _ := strconv.Atoi(fields[2])
-		rodzgmi, _ := strconv.Atoi(fields[3])
-		sym, _ := strconv.Atoi(fields[4])
-		symul, _ := strconv.Atoi(fields[5])
-
-		street := StreetRecord{
-			WOJ:     woj,
-			POW:     pow,
-			GMI:     gmi,
-			RODZGMI: rodzgmi,
-			SYM:     sym,
-			SYMUL:   symul,
-			CECHA:   fields[6],
-			NAZWA1:  fields[7],
-			NAZWA2:  fields[8],
-		}
+// LoadTransitFeeds ingests the GTFS feeds at dirs and geocodes their stops
+// against the currently loaded cities, replacing any previously loaded
+// transit data. It must be called after LoadCitiesCSV.
+func (s *AutocompleteService) LoadTransitFeeds(dirs []string) error {
+	s.mu.RLock()
+	refs := make([]transit.CityRef, len(s.cities))
+	for i, city := range s.cities {
+		refs[i] = transit.CityRef{Name: city.NAZWA, WOJ: city.WOJ}
+	}
+	s.mu.RUnlock()
 
-		// Build full name for display
-		if street.NAZWA2 != "" {
-			street.FullName = fmt.Sprintf("%s %s %s", street.CECHA, street.NAZWA1, street.NAZWA2)
-		} else {
-			street.FullName = fmt.Sprintf("%s %s", street.CECHA, street.NAZWA1)
-		}
+	feeds := make([]transit.FeedConfig, len(dirs))
+	for i, dir := range dirs {
+		feeds[i] = transit.FeedConfig{Dir: dir}
+	}
 
-		s.streets = append(s.streets, street)
+	registry := transit.NewRegistry()
+	if err := registry.Load(feeds, refs); err != nil {
+		return err
 	}
 
-	log.Printf("Loaded %d street records from %s (skipped %d malformed records)", len(s.streets), filename, skipped)
+	s.mu.Lock()
+	s.transitRegistry = registry
+	s.mu.Unlock()
 	return nil
 }
 
-// LoadCitiesCSV loads the city/locality data from SIMC CSV file into memory
-func (s *AutocompleteService) LoadCitiesCSV(filename string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// cityBySYM returns the city whose SYM code matches sym, and whether one
+// was found.
+func (s *AutocompleteService) cityBySYM(sym int) (CityRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+	for _, city := range s.cities {
+		if city.SYM == sym {
+			return city, true
+		}
 	}
-	scanner := strings.NewReader(string(data))
+	return CityRecord{}, false
+}
 
-	s.cities = make([]CityRecord, 0, 100000)
-	lineNum := 0
-	skipped := 0
+// SearchCities performs autocomplete search on city names (NAZWA) with
+// optional administrative-unit filtering, using DefaultSearchOptions(limit).
+func (s *AutocompleteService) SearchCities(ctx context.Context, query string, woj, pow, gmi int, limit int) []CityRecord {
+	matches, _ := s.SearchCitiesWithOptions(ctx, query, woj, pow, gmi, DefaultSearchOptions(limit))
+	results := make([]CityRecord, len(matches))
+	for i, m := range matches {
+		results[i] = m.CityRecord
+	}
+	return results
+}
 
-	// Skip header line
-	_, _ = readLine(scanner)
-	lineNum++
+// SearchCitiesWithOptions is SearchCities with explicit control over match
+// mode, diacritic folding, fuzzy matching, and paging via opts. City names
+// are matched through the diacritic-folded trie/inverted index built at
+// load time, plus the BK-tree when opts.Fuzzy is set, instead of a linear
+// scan. Results are scored exact > prefix > contains > fuzzy(1) >
+// fuzzy(2)..., each fuzzy hit's Distance set to its edit distance from the
+// query (0 otherwise). If ctx is cancelled before the fuzzy pass
+// completes, the index matches gathered so far are still scored and
+// returned, with partial set to true.
+func (s *AutocompleteService) SearchCitiesWithOptions(ctx context.Context, query string, woj, pow, gmi int, opts SearchOptions) (results []CityMatch, partial bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	for {
-		line, err := readLine(scanner)
-		if err != nil {
-			break
-		}
-		lineNum++
+	query = strings.TrimSpace(query)
 
-		// Split by semicolon
-		fields := strings.Split(line, ";")
+	if ctx.Err() != nil {
+		return []CityMatch{}, true
+	}
 
-		// Validate record has exactly 10 fields
-		if len(fields) != 10 {
-			skipped++
-			continue
-		}
+	type candidate struct {
+		rank int
+		dist int
+	}
+	best := make(map[int]candidate)
 
-		// Clean up fields by removing any quotes
-		for i := range fields {
-			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), "\"")
+	if query == "" {
+		// No query: every city is a candidate, administrative filters do
+		// the narrowing below.
+		for i := range s.cities {
+			best[i] = candidate{rank: 0}
 		}
-
-		// Validate essential fields are not empty
-		if fields[6] == "" { // NAZWA must not be empty
-			skipped++
-			continue
+	} else {
+		folded := query
+		if opts.FoldDiacritics {
+			folded = foldName(query)
+		} else {
+			folded = strings.ToLower(query)
 		}
 
-		// Parse integer fields
-		woj, _ := strconv.Atoi(fields[0])
-		pow, _ := strconv.Atoi(fields[1])
-		gmi, _ := strconv.Atoi(fields[2])
-		rodzgmi, _ := strconv.Atoi(fields[3])
-		rm, _ := strconv.Atoi(fields[4])
-		mz, _ := strconv.Atoi(fields[5])
-		sym, _ := strconv.Atoi(fields[7])
-		sympod, _ := strconv.Atoi(fields[8])
-
-		city := CityRecord{
-			WOJ:     woj,
-			POW:     pow,
-			GMI:     gmi,
-			RODZGMI: rodzgmi,
-			RM:      rm,
-			MZ:      mz,
-			NAZWA:   fields[6],
-			SYM:     sym,
-			SYMPOD:  sympod,
+		idxs, _ := matchIndex(s.cityIndex, folded, opts.Mode)
+		for _, idx := range idxs {
+			rank := rankOf(foldName(s.cities[idx].NAZWA), folded)
+			if c, ok := best[idx]; !ok || rank < c.rank {
+				best[idx] = candidate{rank: rank}
+			}
 		}
 
-		s.cities = append(s.cities, city)
-	}
-
-	log.Printf("Loaded %d city records from %s (skipped %d malformed records)", len(s.cities), filename, skipped)
-	return nil
-}
-
-// readLine reads a single line from a strings.Reader
-func readLine(r *strings.Reader) (string, error) {
-	var line strings.Builder
-	for {
-		b, err := r.ReadByte()
-		if err != nil {
-			if line.Len() > 0 {
-				return line.String(), nil
+		if opts.Fuzzy {
+			matches, cancelled := s.cityBK.search(ctx, folded, opts.MaxDist)
+			partial = cancelled
+			for _, m := range matches {
+				if m.dist == 0 {
+					continue // already covered as an exact match above
+				}
+				rank := fuzzyRank(m.dist)
+				if c, ok := best[m.idx]; !ok || rank < c.rank {
+					best[m.idx] = candidate{rank: rank, dist: m.dist}
+				}
 			}
-			return "", err
-		}
-		if b == '\n' {
-			return line.String(), nil
-		}
-		if b != '\r' { // Skip carriage return
-			line.WriteByte(b)
 		}
 	}
-}
 
-// SearchCities performs autocomplete search on city names (NAZWA) with optional filtering
-func (s *AutocompleteService) SearchCities(query string, woj, pow, gmi int, limit int) []CityRecord {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	query = strings.ToLower(strings.TrimSpace(query))
-	results := make([]CityRecord, 0, limit)
-	seen := make(map[string]bool)
+	// Deduplicate by name + administrative codes, keeping the best rank
+	// seen for each.
+	type scoredCity struct {
+		rec  CityRecord
+		rank int
+		dist int
+	}
+	byKey := make(map[string]scoredCity, len(best))
 
-	for _, city := range s.cities {
-		if len(results) >= limit {
-			break
-		}
+	for idx, c := range best {
+		city := s.cities[idx]
 
-		// Apply administrative unit filters if specified (0 means no filter)
 		if woj > 0 && city.WOJ != woj {
 			continue
 		}
@@ -264,81 +254,85 @@ func (s *AutocompleteService) SearchCities(query string, woj, pow, gmi int, limi
 			continue
 		}
 
-		// Search in NAZWA (city name)
-		nazwaLower := strings.ToLower(city.NAZWA)
-
-		// If query is empty, match all (with filters applied above)
-		matchesQuery := query == "" || strings.HasPrefix(nazwaLower, query) || strings.Contains(nazwaLower, query)
-
-		if matchesQuery {
-			// Deduplicate by name + administrative codes
-			key := fmt.Sprintf("%s-%d-%d-%d", city.NAZWA, city.WOJ, city.POW, city.GMI)
-			if !seen[key] {
-				results = append(results, city)
-				seen[key] = true
-			}
+		key := fmt.Sprintf("%s-%d-%d-%d", city.NAZWA, city.WOJ, city.POW, city.GMI)
+		if existing, ok := byKey[key]; !ok || c.rank < existing.rank {
+			byKey[key] = scoredCity{rec: city, rank: c.rank, dist: c.dist}
 		}
 	}
 
-	// Sort results: prefix matches first, then contains matches, then alphabetically
-	sort.Slice(results, func(i, j int) bool {
-		if query == "" {
-			return results[i].NAZWA < results[j].NAZWA
-		}
-
-		nazwaI := strings.ToLower(results[i].NAZWA)
-		nazwaJ := strings.ToLower(results[j].NAZWA)
-
-		prefixI := strings.HasPrefix(nazwaI, query)
-		prefixJ := strings.HasPrefix(nazwaJ, query)
+	scored := make([]scoredCity, 0, len(byKey))
+	for _, sc := range byKey {
+		scored = append(scored, sc)
+	}
 
-		if prefixI && !prefixJ {
-			return true
-		}
-		if !prefixI && prefixJ {
-			return false
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].rank != scored[j].rank {
+			return scored[i].rank < scored[j].rank
 		}
-
-		return results[i].NAZWA < results[j].NAZWA
+		return scored[i].rec.NAZWA < scored[j].rec.NAZWA
 	})
 
-	return results
+	offset := opts.Offset
+	if offset > len(scored) {
+		offset = len(scored)
+	}
+	scored = scored[offset:]
+	if opts.Limit > 0 && opts.Limit < len(scored) {
+		scored = scored[:opts.Limit]
+	}
+
+	results = make([]CityMatch, 0, len(scored))
+	for _, sc := range scored {
+		results = append(results, CityMatch{CityRecord: sc.rec, Distance: sc.dist})
+	}
+	return results, partial
 }
 
-// GetGMIForStreet returns unique GMI codes where the exact street name exists
-func (s *AutocompleteService) GetGMIForStreet(streetName string) []map[string]interface{} {
+// GetGMIForStreet returns unique GMI codes where the exact street name
+// exists, along with whether the scan completed fully. It is the one
+// remaining true linear scan over s.streets (the name isn't indexed by
+// exact-match), so it shards the scan across scanShards rather than
+// walking s.streets on the caller's goroutine; ctx is checked every
+// scanBatch records, and a cancelled ctx yields a partial=true result
+// made up of whatever shards finished in time.
+func (s *AutocompleteService) GetGMIForStreet(ctx context.Context, streetName string) (results []map[string]interface{}, partial bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	streetName = strings.TrimSpace(streetName)
 	if streetName == "" {
-		return []map[string]interface{}{}
+		return []map[string]interface{}{}, false
 	}
-
 	streetNameLower := strings.ToLower(streetName)
-	seen := make(map[string]bool)
-	var results []map[string]interface{}
-
-	for _, street := range s.streets {
-		nazwa1Lower := strings.ToLower(street.NAZWA1)
-
-		// Exact match on NAZWA_1
-		if nazwa1Lower == streetNameLower {
-			// Create unique key for WOJ-POW-GMI combination
-			key := fmt.Sprintf("%d-%d-%d", street.WOJ, street.POW, street.GMI)
-
-			if !seen[key] {
-				results = append(results, map[string]interface{}{
-					"woj": street.WOJ,
-					"pow": street.POW,
-					"gmi": street.GMI,
-				})
-				seen[key] = true
+
+	type hit struct{ woj, pow, gmi int }
+	var mu sync.Mutex
+	seen := make(map[hit]bool)
+
+	scanShards(ctx, len(s.streets), func(ctx context.Context, lo, hi int) {
+		var shardHits []hit
+		for i := lo; i < hi; i++ {
+			if scanCancelled(ctx, i-lo) {
+				return
+			}
+			street := s.streets[i]
+			if strings.ToLower(street.NAZWA1) == streetNameLower {
+				shardHits = append(shardHits, hit{street.WOJ, street.POW, street.GMI})
 			}
 		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, h := range shardHits {
+			seen[h] = true
+		}
+	})
+
+	results = make([]map[string]interface{}, 0, len(seen))
+	for h := range seen {
+		results = append(results, map[string]interface{}{"woj": h.woj, "pow": h.pow, "gmi": h.gmi})
 	}
 
-	// Sort by WOJ, POW, GMI
 	sort.Slice(results, func(i, j int) bool {
 		if results[i]["woj"].(int) != results[j]["woj"].(int) {
 			return results[i]["woj"].(int) < results[j]["woj"].(int)
@@ -349,105 +343,279 @@ func (s *AutocompleteService) GetGMIForStreet(streetName string) []map[string]in
 		return results[i]["gmi"].(int) < results[j]["gmi"].(int)
 	})
 
+	return results, ctx.Err() != nil
+}
+
+// Search performs autocomplete search on NAZWA_1 (street name) using
+// DefaultSearchOptions(limit).
+func (s *AutocompleteService) Search(ctx context.Context, query string, limit int) []StreetRecord {
+	matches, _ := s.SearchWithOptions(ctx, query, DefaultSearchOptions(limit))
+	results := make([]StreetRecord, len(matches))
+	for i, m := range matches {
+		results[i] = m.StreetRecord
+	}
 	return results
 }
 
-// Search performs autocomplete search on NAZWA_1 (street name)
-func (s *AutocompleteService) Search(query string, limit int) []StreetRecord {
+// SearchWithOptions is Search with explicit control over match mode,
+// diacritic folding, fuzzy matching, and paging via opts. Street names are
+// matched through the diacritic-folded trie/inverted index built at load
+// time, plus the BK-tree when opts.Fuzzy is set, instead of a linear scan.
+// Results are scored exact > prefix > contains > fuzzy(1) > fuzzy(2)...,
+// each fuzzy hit's Distance set to its edit distance from the query (0
+// otherwise). If ctx is cancelled before the fuzzy pass completes, the
+// index matches gathered so far are still scored and returned, with
+// partial set to true.
+func (s *AutocompleteService) SearchWithOptions(ctx context.Context, query string, opts SearchOptions) (results []StreetMatch, partial bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	query = strings.TrimSpace(query)
 	if query == "" {
-		return []StreetRecord{}
+		return []StreetMatch{}, false
 	}
 
-	query = strings.ToLower(strings.TrimSpace(query))
-	results := make([]StreetRecord, 0, limit)
-	seen := make(map[string]bool)
+	folded := query
+	if opts.FoldDiacritics {
+		folded = foldName(query)
+	} else {
+		folded = strings.ToLower(query)
+	}
 
-	for _, street := range s.streets {
-		if len(results) >= limit {
-			break
-		}
+	type candidate struct {
+		rank int
+		dist int
+	}
+	best := make(map[int]candidate)
 
-		// Search in NAZWA_1 (main street name)
-		nazwa1Lower := strings.ToLower(street.NAZWA1)
+	if ctx.Err() != nil {
+		return []StreetMatch{}, true
+	}
 
-		if strings.HasPrefix(nazwa1Lower, query) || strings.Contains(nazwa1Lower, query) {
-			// Deduplicate by full name
-			if !seen[street.FullName] {
-				results = append(results, street)
-				seen[street.FullName] = true
-			}
+	idxs, _ := matchIndex(s.streetIndex, folded, opts.Mode)
+	for _, idx := range idxs {
+		rank := rankOf(foldName(s.streets[idx].NAZWA1), folded)
+		if c, ok := best[idx]; !ok || rank < c.rank {
+			best[idx] = candidate{rank: rank}
 		}
 	}
 
-	// Sort results: prefix matches first, then contains matches
-	sort.Slice(results, func(i, j int) bool {
-		nazwa1i := strings.ToLower(results[i].NAZWA1)
-		nazwa1j := strings.ToLower(results[j].NAZWA1)
+	if opts.Fuzzy {
+		matches, cancelled := s.streetBK.search(ctx, folded, opts.MaxDist)
+		partial = cancelled
+		for _, m := range matches {
+			if m.dist == 0 {
+				continue // already covered as an exact match above
+			}
+			rank := fuzzyRank(m.dist)
+			if c, ok := best[m.idx]; !ok || rank < c.rank {
+				best[m.idx] = candidate{rank: rank, dist: m.dist}
+			}
+		}
+	}
 
-		prefixI := strings.HasPrefix(nazwa1i, query)
-		prefixJ := strings.HasPrefix(nazwa1j, query)
+	// Deduplicate by full name, keeping the best rank seen for each.
+	type scoredStreet struct {
+		rec  StreetRecord
+		rank int
+		dist int
+	}
+	byName := make(map[string]scoredStreet, len(best))
 
-		if prefixI && !prefixJ {
-			return true
-		}
-		if !prefixI && prefixJ {
-			return false
+	for idx, c := range best {
+		street := s.streets[idx]
+		if existing, ok := byName[street.FullName]; !ok || c.rank < existing.rank {
+			byName[street.FullName] = scoredStreet{rec: street, rank: c.rank, dist: c.dist}
 		}
+	}
 
-		return results[i].NAZWA1 < results[j].NAZWA1
+	scored := make([]scoredStreet, 0, len(byName))
+	for _, sc := range byName {
+		scored = append(scored, sc)
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].rank != scored[j].rank {
+			return scored[i].rank < scored[j].rank
+		}
+		return scored[i].rec.NAZWA1 < scored[j].rec.NAZWA1
 	})
 
-	return results
+	offset := opts.Offset
+	if offset > len(scored) {
+		offset = len(scored)
+	}
+	scored = scored[offset:]
+	if opts.Limit > 0 && opts.Limit < len(scored) {
+		scored = scored[:opts.Limit]
+	}
+
+	results = make([]StreetMatch, 0, len(scored))
+	for _, sc := range scored {
+		results = append(results, StreetMatch{StreetRecord: sc.rec, Distance: sc.dist})
+	}
+	return results, partial
 }
 
 // AutocompleteResponse is the JSON response structure for streets
 type AutocompleteResponse struct {
 	Query   string         `json:"query"`
 	Results []StreetRecord `json:"results"`
-	Count   int            `json:"count"`
-	Time    string         `json:"time"`
+	// Distances holds the Damerau-Levenshtein edit distance for each entry
+	// in Results, parallel by index. Only populated for fuzzy searches.
+	Distances []int `json:"distances,omitempty"`
+	Count     int   `json:"count"`
+	// Partial is true when --query-timeout fired before the search
+	// finished; Results then reflects whatever was gathered before the
+	// deadline rather than the full result set.
+	Partial          bool   `json:"partial,omitempty"`
+	DeadlineExceeded bool   `json:"deadline_exceeded,omitempty"`
+	Time             string `json:"time"`
 }
 
 // CityAutocompleteResponse is the JSON response structure for cities
 type CityAutocompleteResponse struct {
-	Query   string       `json:"query"`
+	Query   string         `json:"query"`
 	Filters map[string]int `json:"filters,omitempty"`
-	Results []CityRecord `json:"results"`
-	Count   int          `json:"count"`
-	Time    string       `json:"time"`
+	Results []CityRecord   `json:"results"`
+	// Distances holds the Damerau-Levenshtein edit distance for each entry
+	// in Results, parallel by index. Only populated for fuzzy searches.
+	Distances []int `json:"distances,omitempty"`
+	// TransitSummaries holds each Results entry's transit stop count and
+	// serving agencies, parallel by index; an entry is nil if no transit
+	// feed was loaded or none of its stops geocoded to that city. See
+	// GET /cities/{sym}/stops for the full stop list.
+	TransitSummaries []*transit.CitySummary `json:"transit_summaries,omitempty"`
+	Count            int                    `json:"count"`
+	// Partial is true when --query-timeout fired before the search
+	// finished; Results then reflects whatever was gathered before the
+	// deadline rather than the full result set.
+	Partial          bool   `json:"partial,omitempty"`
+	DeadlineExceeded bool   `json:"deadline_exceeded,omitempty"`
+	Time             string `json:"time"`
+}
+
+// CityStopsResponse is the JSON response structure for GET
+// /cities/{sym}/stops.
+type CityStopsResponse struct {
+	City  CityRecord            `json:"city"`
+	Stops []transit.StopSummary `json:"stops"`
+	transit.CitySummary
 }
 
 var service *AutocompleteService
 
+// queryTimeout bounds how long a single search request is allowed to run
+// before its context is cancelled; set from the --query-timeout flag.
+var queryTimeout = 250 * time.Millisecond
+
 func main() {
+	transitFeeds := flag.String("transit-feeds", "", "comma-separated list of GTFS feed directories to geocode against loaded cities")
+	flag.DurationVar(&queryTimeout, "query-timeout", queryTimeout, "deadline enforced on each search request (streets/cities/search)")
+	snapshotPath := flag.String("snapshot", "", "path to a street/city snapshot cache; loaded instead of re-parsing the CSVs when its fingerprint still matches them, and rewritten after a fresh CSV load")
+	streetsURL := flag.String("streets-url", "", "URL to fetch the ULIC streets CSV from (raw or zipped) instead of reading streetFile from disk")
+	citiesURL := flag.String("cities-url", "", "URL to fetch the SIMC cities CSV from (raw or zipped) instead of reading cityFile from disk")
+	flag.Parse()
+
 	// Initialize service
 	service = NewAutocompleteService()
 
-	// Load street data
 	streetFile := "data/ULIC_Adresowy_2025-12-01.csv"
-	log.Printf("Loading street data from %s...", streetFile)
-	startTime := time.Now()
-	if err := service.LoadCSV(streetFile); err != nil {
-		log.Fatalf("Failed to load streets CSV: %v", err)
+	cityFile := "data/SIMC_Adresowy_2025-12-01.csv"
+
+	if *streetsURL != "" || *citiesURL != "" {
+		// A remote source is fetched fresh every startup (LoadStreetsFromURL
+		// / LoadCitiesFromURL already cache the payload by ETag), so the
+		// snapshot fingerprinting below, which is keyed to local file paths,
+		// doesn't apply here.
+		if *streetsURL != "" {
+			log.Printf("Loading street data from %s...", *streetsURL)
+			startTime := time.Now()
+			if err := service.LoadStreetsFromURL(context.Background(), *streetsURL); err != nil {
+				log.Fatalf("Failed to load streets from %s: %v", *streetsURL, err)
+			}
+			log.Printf("Streets loaded in %v", time.Since(startTime))
+		} else {
+			log.Printf("Loading street data from %s...", streetFile)
+			startTime := time.Now()
+			if err := service.LoadCSV(streetFile); err != nil {
+				log.Fatalf("Failed to load streets CSV: %v", err)
+			}
+			log.Printf("Streets loaded in %v", time.Since(startTime))
+		}
+
+		if *citiesURL != "" {
+			log.Printf("Loading city data from %s...", *citiesURL)
+			startTime := time.Now()
+			if err := service.LoadCitiesFromURL(context.Background(), *citiesURL); err != nil {
+				log.Fatalf("Failed to load cities from %s: %v", *citiesURL, err)
+			}
+			log.Printf("Cities loaded in %v", time.Since(startTime))
+		} else {
+			log.Printf("Loading city data from %s...", cityFile)
+			startTime := time.Now()
+			if err := service.LoadCitiesCSV(cityFile); err != nil {
+				log.Fatalf("Failed to load cities CSV: %v", err)
+			}
+			log.Printf("Cities loaded in %v", time.Since(startTime))
+		}
+	} else {
+		fromSnapshot := false
+		if *snapshotPath != "" {
+			startTime := time.Now()
+			ok, err := service.LoadSnapshot(*snapshotPath, streetFile, cityFile)
+			if err != nil {
+				log.Printf("Failed to load snapshot %s, falling back to CSV: %v", *snapshotPath, err)
+			} else if ok {
+				fromSnapshot = true
+				log.Printf("Loaded %d streets and %d cities from snapshot %s in %v", len(service.streets), len(service.cities), *snapshotPath, time.Since(startTime))
+			}
+		}
+
+		if !fromSnapshot {
+			// Load street data
+			log.Printf("Loading street data from %s...", streetFile)
+			startTime := time.Now()
+			if err := service.LoadCSV(streetFile); err != nil {
+				log.Fatalf("Failed to load streets CSV: %v", err)
+			}
+			log.Printf("Streets loaded in %v", time.Since(startTime))
+
+			// Load city data
+			log.Printf("Loading city data from %s...", cityFile)
+			startTime = time.Now()
+			if err := service.LoadCitiesCSV(cityFile); err != nil {
+				log.Fatalf("Failed to load cities CSV: %v", err)
+			}
+			log.Printf("Cities loaded in %v", time.Since(startTime))
+
+			if *snapshotPath != "" {
+				if err := service.SaveSnapshot(*snapshotPath, streetFile, cityFile); err != nil {
+					log.Printf("Failed to write snapshot %s: %v", *snapshotPath, err)
+				} else {
+					log.Printf("Wrote snapshot to %s", *snapshotPath)
+				}
+			}
+		}
 	}
-	log.Printf("Streets loaded in %v", time.Since(startTime))
 
-	// Load city data
-	cityFile := "data/SIMC_Adresowy_2025-12-01.csv"
-	log.Printf("Loading city data from %s...", cityFile)
-	startTime = time.Now()
-	if err := service.LoadCitiesCSV(cityFile); err != nil {
-		log.Fatalf("Failed to load cities CSV: %v", err)
+	if *transitFeeds != "" {
+		dirs := strings.Split(*transitFeeds, ",")
+		log.Printf("Loading GTFS transit feeds: %v...", dirs)
+		startTime := time.Now()
+		if err := service.LoadTransitFeeds(dirs); err != nil {
+			log.Fatalf("Failed to load transit feeds: %v", err)
+		}
+		log.Printf("Transit feeds loaded in %v", time.Since(startTime))
 	}
-	log.Printf("Cities loaded in %v", time.Since(startTime))
 
 	// Setup HTTP routes
-	http.HandleFunc("/streets", autocompleteHandler)
+	http.HandleFunc("/streets", streetsHandler)
 	http.HandleFunc("/streets/gmi", streetGMIHandler)
 	http.HandleFunc("/cities", citiesHandler)
+	http.HandleFunc("/cities/", cityStopsHandler)
+	http.HandleFunc("/admin/load-errors", adminLoadErrorsHandler)
+	http.HandleFunc("/search", searchHandler)
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/", rootHandler)
 
@@ -460,201 +628,3 @@ func main() {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
-
-func autocompleteHandler(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
-
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get query parameter
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		json.NewEncoder(w).Encode(AutocompleteResponse{
-			Query:   "",
-			Results: []StreetRecord{},
-			Count:   0,
-			Time:    time.Since(startTime).String(),
-		})
-		return
-	}
-
-	// Default limit
-	limit := 10
-
-	// Search
-	results := service.Search(query, limit)
-
-	// Build response
-	response := AutocompleteResponse{
-		Query:   query,
-		Results: results,
-		Count:   len(results),
-		Time:    time.Since(startTime).String(),
-	}
-
-	json.NewEncoder(w).Encode(response)
-}
-
-func citiesHandler(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
-
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get query parameter
-	query := r.URL.Query().Get("q")
-
-	// Get filter parameters (0 means no filter)
-	woj, _ := strconv.Atoi(r.URL.Query().Get("woj"))
-	pow, _ := strconv.Atoi(r.URL.Query().Get("pow"))
-	gmi, _ := strconv.Atoi(r.URL.Query().Get("gmi"))
-
-	// Default limit
-	limit := 10
-
-	// Search with filters
-	results := service.SearchCities(query, woj, pow, gmi, limit)
-
-	// Build filters map for response
-	filters := make(map[string]int)
-	if woj > 0 {
-		filters["woj"] = woj
-	}
-	if pow > 0 {
-		filters["pow"] = pow
-	}
-	if gmi > 0 {
-		filters["gmi"] = gmi
-	}
-
-	// Build response
-	response := CityAutocompleteResponse{
-		Query:   query,
-		Filters: filters,
-		Results: results,
-		Count:   len(results),
-		Time:    time.Since(startTime).String(),
-	}
-
-	json.NewEncoder(w).Encode(response)
-}
-
-func streetGMIHandler(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
-
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get street name parameter
-	streetName := r.URL.Query().Get("name")
-	if streetName == "" {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "missing 'name' parameter",
-			"results": []map[string]interface{}{},
-			"count":   0,
-			"time":    time.Since(startTime).String(),
-		})
-		return
-	}
-
-	// Get GMI codes for the exact street name
-	results := service.GetGMIForStreet(streetName)
-
-	// Build response
-	response := map[string]interface{}{
-		"street_name": streetName,
-		"results":     results,
-		"count":       len(results),
-		"time":        time.Since(startTime).String(),
-	}
-
-	json.NewEncoder(w).Encode(response)
-}
-
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "ok",
-		"streets": fmt.Sprintf("%d", len(service.streets)),
-		"cities":  fmt.Sprintf("%d", len(service.cities)),
-	})
-}
-
-func rootHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html")
-	html := `<!DOCTYPE html>
-<html>
-<head>
-    <title>Street Autocomplete API</title>
-    <style>
-        body { font-family: Arial, sans-serif; max-width: 800px; margin: 50px auto; padding: 20px; }
-        h1 { color: #333; }
-        .endpoint { background: #f4f4f4; padding: 10px; margin: 10px 0; border-radius: 5px; }
-        code { background: #e0e0e0; padding: 2px 5px; border-radius: 3px; }
-        input { padding: 10px; width: 300px; font-size: 16px; }
-        #results { margin-top: 20px; }
-        .result { padding: 8px; margin: 5px 0; background: #f9f9f9; border-left: 3px solid #4CAF50; }
-    </style>
-</head>
-<body>
-    <h1>Polish Street Autocomplete API</h1>
-    <p>Try the autocomplete:</p>
-    <input type="text" id="search" placeholder="Type street name..." onkeyup="search()">
-    <div id="results"></div>
-
-    <h2>API Endpoints</h2>
-    <div class="endpoint">
-        <strong>GET /streets?q={query}</strong><br>
-        Search for streets by name<br>
-        Example: <a href="/streets?q=Chopina">/streets?q=Chopina</a>
-    </div>
-    <div class="endpoint">
-        <strong>GET /streets/gmi?name={exact_street_name}</strong><br>
-        Get list of GMI codes where an exact street name exists<br>
-        Example: <a href="/streets/gmi?name=Sportowa">/streets/gmi?name=Sportowa</a>
-    </div>
-    <div class="endpoint">
-        <strong>GET /cities?q={query}&woj={woj}&pow={pow}&gmi={gmi}</strong><br>
-        Search for cities by name with optional filters<br>
-        Examples:<br>
-        - <a href="/cities?q=Warszawa">/cities?q=Warszawa</a><br>
-        - <a href="/cities?q=Krak&woj=12">/cities?q=Krak&woj=12</a> (filter by województwo)<br>
-        - <a href="/cities?woj=14&pow=32">/cities?woj=14&pow=32</a> (all cities in powiat)
-    </div>
-    <div class="endpoint">
-        <strong>GET /health</strong><br>
-        Example: <a href="/health">/health</a>
-    </div>
-
-    <script>
-        let timeout = null;
-        function search() {
-            clearTimeout(timeout);
-            const query = document.getElementById('search').value;
-
-            if (query.length < 2) {
-                document.getElementById('results').innerHTML = '';
-                return;
-            }
-
-            timeout = setTimeout(() => {
-                fetch('/streets?q=' + encodeURIComponent(query))
-                    .then(r => r.json())
-                    .then(data => {
-                        const html = data.results.map(r =>
-                            '<div class="result">' + r.full_name + '</div>'
-                        ).join('');
-                        document.getElementById('results').innerHTML =
-                            '<p>Found ' + data.count + ' results in ' + data.time + '</p>' + html;
-                    });
-            }, 200);
-        }
-    </script>
-</body>
-</html>`
-	w.Write([]byte(html))
-}
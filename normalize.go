@@ -0,0 +1,24 @@
+package main
+
+import "strings"
+
+// diacriticFolder strips the Polish diacritics the TERYT datasets use so that
+// an ASCII query like "lodz" can match "Łódź".
+var diacriticFolder = strings.NewReplacer(
+	"ł", "l", "Ł", "L",
+	"ą", "a", "Ą", "A",
+	"ę", "e", "Ę", "E",
+	"ś", "s", "Ś", "S",
+	"ż", "z", "Ż", "Z",
+	"ź", "z", "Ź", "Z",
+	"ć", "c", "Ć", "C",
+	"ń", "n", "Ń", "N",
+	"ó", "o", "Ó", "O",
+)
+
+// foldName lowercases s and folds Polish diacritics out of it. It is applied
+// both when indexing record names and when normalizing incoming queries, so
+// the two sides always compare on the same alphabet.
+func foldName(s string) string {
+	return strings.ToLower(diacriticFolder.Replace(s))
+}
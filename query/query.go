@@ -0,0 +1,53 @@
+// Package query defines the compound query DSL accepted by POST /search:
+// a bool query over streets, cities, and the WOJ/POW/GMI administrative
+// hierarchy, modeled on Elasticsearch's bool query. This package only
+// describes the query shape; evaluating it against the loaded TERYT data
+// is the AutocompleteService's job, since that's where the indexes live.
+package query
+
+// Condition is a single leaf condition. Exactly one field should be set;
+// if more than one is set, all of them must match (they are ANDed as one
+// condition rather than treated as alternatives).
+type Condition struct {
+	StreetPrefix   string `json:"street_prefix,omitempty"`
+	StreetContains string `json:"street_contains,omitempty"`
+	CityPrefix     string `json:"city_prefix,omitempty"`
+	CityContains   string `json:"city_contains,omitempty"`
+	Cecha          string `json:"cecha,omitempty"`
+}
+
+// Empty reports whether c has no field set.
+func (c Condition) Empty() bool {
+	return c == Condition{}
+}
+
+// Filter narrows a Query by administrative unit without affecting score.
+// Woj is a single województwo code; Pow and Gmi accept a list of codes so
+// callers can filter by several powiats/gminas at once. Zero/empty means
+// "no filter" for that field.
+type Filter struct {
+	Woj int   `json:"woj,omitempty"`
+	Pow []int `json:"pow,omitempty"`
+	Gmi []int `json:"gmi,omitempty"`
+}
+
+// Empty reports whether f narrows nothing.
+func (f Filter) Empty() bool {
+	return f.Woj == 0 && len(f.Pow) == 0 && len(f.Gmi) == 0
+}
+
+// Query is a compound bool query, analogous to Elasticsearch's bool query:
+// every Must condition has to match, Filter narrows the candidate set
+// without affecting score, and Should conditions each boost a result's
+// score by one when they match.
+type Query struct {
+	Must   []Condition `json:"must,omitempty"`
+	Filter Filter      `json:"filter,omitempty"`
+	Should []Condition `json:"should,omitempty"`
+	Sort   []string    `json:"sort,omitempty"`
+	Limit  int         `json:"limit,omitempty"`
+	Offset int         `json:"offset,omitempty"`
+}
+
+// DefaultLimit is applied when a Query doesn't specify one.
+const DefaultLimit = 50
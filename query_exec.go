@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/slav123/teryt-autocomplete/query"
+)
+
+// JoinedResult is one row of a /search response: a street and/or city hit.
+// Both are populated when a Query constrains streets and cities together,
+// joined on the WOJ/POW/GMI gmina they share; otherwise only the side the
+// Query actually constrained is set.
+type JoinedResult struct {
+	Street *StreetRecord `json:"street,omitempty"`
+	City   *CityRecord   `json:"city,omitempty"`
+	Score  int           `json:"score"`
+}
+
+// gminaKey identifies one WOJ/POW/GMI administrative unit, the join key
+// between streets and cities.
+type gminaKey struct{ woj, pow, gmi int }
+
+func streetGminaKey(s StreetRecord) gminaKey { return gminaKey{s.WOJ, s.POW, s.GMI} }
+func cityGminaKey(c CityRecord) gminaKey     { return gminaKey{c.WOJ, c.POW, c.GMI} }
+
+// matchSet is a candidate set of record indices built up by ANDing Must
+// conditions. The zero value is unconstrained (matches everything) until
+// the first intersect call narrows it.
+type matchSet struct {
+	idxs    map[int]bool
+	touched bool
+}
+
+// intersect narrows the set to idxs, ANDed with whatever the set already
+// contained.
+func (m *matchSet) intersect(idxs []int) {
+	next := make(map[int]bool, len(idxs))
+	for _, idx := range idxs {
+		if !m.touched || m.idxs[idx] {
+			next[idx] = true
+		}
+	}
+	m.idxs = next
+	m.touched = true
+}
+
+// keys returns the set's current indices as a slice, for handing off to
+// another matchSet's intersect.
+func (m matchSet) keys() []int {
+	keys := make([]int, 0, len(m.idxs))
+	for idx := range m.idxs {
+		keys = append(keys, idx)
+	}
+	return keys
+}
+
+// streetsByCecha linearly scans for streets whose CECHA (street-type
+// prefix, e.g. "ul.", "al.") matches exactly; there is no index for it
+// since it is a small, low-cardinality field. ctx is checked every
+// scanBatch records; a cancelled ctx returns whatever was found so far
+// along with partial=true.
+func (s *AutocompleteService) streetsByCecha(ctx context.Context, cecha string) (idxs []int, partial bool) {
+	for i, street := range s.streets {
+		if scanCancelled(ctx, i) {
+			return idxs, true
+		}
+		if strings.EqualFold(street.CECHA, cecha) {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs, false
+}
+
+// conditionMatchesStreet reports whether c's street-targeting fields all
+// match st (per query.Condition's doc: multiple fields set on one
+// Condition are ANDed, not alternatives). Reports false if c has no
+// street-targeting field set. Used to score Should conditions against an
+// already-resolved result.
+func conditionMatchesStreet(c query.Condition, st StreetRecord) bool {
+	folded := foldName(st.NAZWA1)
+	matched := false
+	if c.StreetPrefix != "" {
+		if !strings.HasPrefix(folded, foldName(c.StreetPrefix)) {
+			return false
+		}
+		matched = true
+	}
+	if c.StreetContains != "" {
+		if !strings.Contains(folded, foldName(c.StreetContains)) {
+			return false
+		}
+		matched = true
+	}
+	if c.Cecha != "" {
+		if !strings.EqualFold(st.CECHA, c.Cecha) {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// conditionMatchesCity is conditionMatchesStreet for c's city-targeting
+// fields.
+func conditionMatchesCity(c query.Condition, ct CityRecord) bool {
+	folded := foldName(ct.NAZWA)
+	matched := false
+	if c.CityPrefix != "" {
+		if !strings.HasPrefix(folded, foldName(c.CityPrefix)) {
+			return false
+		}
+		matched = true
+	}
+	if c.CityContains != "" {
+		if !strings.Contains(folded, foldName(c.CityContains)) {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+func containsInt(haystack []int, needle int) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func passesFilterStreet(f query.Filter, st StreetRecord) bool {
+	if f.Woj != 0 && st.WOJ != f.Woj {
+		return false
+	}
+	if len(f.Pow) > 0 && !containsInt(f.Pow, st.POW) {
+		return false
+	}
+	if len(f.Gmi) > 0 && !containsInt(f.Gmi, st.GMI) {
+		return false
+	}
+	return true
+}
+
+func passesFilterCity(f query.Filter, ct CityRecord) bool {
+	if f.Woj != 0 && ct.WOJ != f.Woj {
+		return false
+	}
+	if len(f.Pow) > 0 && !containsInt(f.Pow, ct.POW) {
+		return false
+	}
+	if len(f.Gmi) > 0 && !containsInt(f.Gmi, ct.GMI) {
+		return false
+	}
+	return true
+}
+
+// RunQuery evaluates q against the loaded data and returns the matching
+// rows: Must conditions narrow the street/city candidate sets (intersected
+// with the trie/inverted index built by buildStreetIndex/buildCityIndex),
+// Filter narrows further by administrative unit, and Should conditions
+// each add one to a result's Score. If both streets and cities were
+// constrained, rows are joined on their shared WOJ/POW/GMI gmina. Per
+// query.Condition's doc comment, every non-empty field on one Condition is
+// ANDed together (e.g. StreetPrefix and Cecha both set means both must
+// match the same street) rather than only the first non-empty field being
+// honored. The only true linear scan here is a Cecha condition or the
+// default (unconstrained or street-only) browse, so ctx is checked during
+// those; a cancelled ctx yields whatever was found before the deadline,
+// with partial=true.
+func (s *AutocompleteService) RunQuery(ctx context.Context, q query.Query) (results []JoinedResult, partial bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var streetSet, citySet matchSet
+
+	for _, c := range q.Must {
+		var streetCond, cityCond matchSet
+
+		if c.StreetPrefix != "" {
+			streetCond.intersect(s.streetIndex.trie.prefixSearch(foldName(c.StreetPrefix)))
+		}
+		if c.StreetContains != "" {
+			streetCond.intersect(s.streetIndex.inverted.containsSearch(foldName(c.StreetContains)))
+		}
+		if c.Cecha != "" {
+			idxs, p := s.streetsByCecha(ctx, c.Cecha)
+			streetCond.intersect(idxs)
+			partial = partial || p
+		}
+		if c.CityPrefix != "" {
+			cityCond.intersect(s.cityIndex.trie.prefixSearch(foldName(c.CityPrefix)))
+		}
+		if c.CityContains != "" {
+			cityCond.intersect(s.cityIndex.inverted.containsSearch(foldName(c.CityContains)))
+		}
+
+		if streetCond.touched {
+			streetSet.intersect(streetCond.keys())
+		}
+		if cityCond.touched {
+			citySet.intersect(cityCond.keys())
+		}
+	}
+
+	switch {
+	case streetSet.touched && citySet.touched:
+		// Join: group the constrained cities by gmina, then pair each
+		// constrained street against the cities in its own gmina.
+		citiesByGmina := make(map[gminaKey][]int)
+		for idx := range citySet.idxs {
+			ct := s.cities[idx]
+			if !passesFilterCity(q.Filter, ct) {
+				continue
+			}
+			key := cityGminaKey(ct)
+			citiesByGmina[key] = append(citiesByGmina[key], idx)
+		}
+		for idx := range streetSet.idxs {
+			st := s.streets[idx]
+			if !passesFilterStreet(q.Filter, st) {
+				continue
+			}
+			for _, cityIdx := range citiesByGmina[streetGminaKey(st)] {
+				st, ct := st, s.cities[cityIdx]
+				results = append(results, JoinedResult{Street: &st, City: &ct})
+			}
+		}
+
+	case citySet.touched:
+		for idx := range citySet.idxs {
+			ct := s.cities[idx]
+			if !passesFilterCity(q.Filter, ct) {
+				continue
+			}
+			results = append(results, JoinedResult{City: &ct})
+		}
+
+	default:
+		// No constraint (or a street-only constraint): browse streets,
+		// optionally narrowed by streetSet and always by Filter.
+		for idx := range s.streets {
+			if scanCancelled(ctx, idx) {
+				partial = true
+				break
+			}
+			if streetSet.touched && !streetSet.idxs[idx] {
+				continue
+			}
+			st := s.streets[idx]
+			if !passesFilterStreet(q.Filter, st) {
+				continue
+			}
+			results = append(results, JoinedResult{Street: &st})
+		}
+	}
+
+	for i := range results {
+		for _, c := range q.Should {
+			if (results[i].Street != nil && conditionMatchesStreet(c, *results[i].Street)) ||
+				(results[i].City != nil && conditionMatchesCity(c, *results[i].City)) {
+				results[i].Score++
+			}
+		}
+	}
+
+	sortJoinedResults(results, q.Sort)
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = query.DefaultLimit
+	}
+	offset := q.Offset
+	if offset > len(results) {
+		offset = len(results)
+	}
+	results = results[offset:]
+	if limit < len(results) {
+		results = results[:limit]
+	}
+
+	return results, partial
+}
+
+// sortJoinedResults sorts results by the given field names ("woj", "pow",
+// "gmi", "nazwa"), each ascending, falling back to Score descending (then
+// WOJ/POW/GMI ascending) when no fields are given.
+func sortJoinedResults(results []JoinedResult, fields []string) {
+	adminCode := func(r JoinedResult, field string) int {
+		switch {
+		case r.Street != nil:
+			switch field {
+			case "woj":
+				return r.Street.WOJ
+			case "pow":
+				return r.Street.POW
+			case "gmi":
+				return r.Street.GMI
+			}
+		case r.City != nil:
+			switch field {
+			case "woj":
+				return r.City.WOJ
+			case "pow":
+				return r.City.POW
+			case "gmi":
+				return r.City.GMI
+			}
+		}
+		return 0
+	}
+	name := func(r JoinedResult) string {
+		if r.Street != nil {
+			return r.Street.FullName
+		}
+		if r.City != nil {
+			return r.City.NAZWA
+		}
+		return ""
+	}
+
+	if len(fields) == 0 {
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].Score != results[j].Score {
+				return results[i].Score > results[j].Score
+			}
+			for _, f := range []string{"woj", "pow", "gmi"} {
+				ci, cj := adminCode(results[i], f), adminCode(results[j], f)
+				if ci != cj {
+					return ci < cj
+				}
+			}
+			return name(results[i]) < name(results[j])
+		})
+		return
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		for _, f := range fields {
+			if f == "nazwa" {
+				if ni, nj := name(results[i]), name(results[j]); ni != nj {
+					return ni < nj
+				}
+				continue
+			}
+			if ci, cj := adminCode(results[i], f), adminCode(results[j], f); ci != cj {
+				return ci < cj
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slav123/teryt-autocomplete/query"
+)
+
+func newQueryTestService(t *testing.T) *AutocompleteService {
+	t.Helper()
+	s := NewAutocompleteService()
+	streets := []StreetRecord{
+		{WOJ: 2, POW: 1, GMI: 1, SYM: 1, SYMUL: 1, CECHA: "ul.", NAZWA1: "Chopina", FullName: "ul. Chopina"},
+		{WOJ: 2, POW: 1, GMI: 1, SYM: 1, SYMUL: 2, CECHA: "al.", NAZWA1: "Chopina", FullName: "al. Chopina"},
+	}
+	if _, err := s.Load(context.Background(), NewFixtureStreetSource(streets)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return s
+}
+
+func TestRunQueryConditionANDsAllSetFields(t *testing.T) {
+	s := newQueryTestService(t)
+
+	q := query.Query{Must: []query.Condition{{StreetPrefix: "Chop", Cecha: "al."}}}
+	results, partial := s.RunQuery(context.Background(), q)
+	if partial {
+		t.Fatal("expected query to complete")
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected exactly 1 result (al. Chopina only), got %d: %+v", len(results), results)
+	}
+	if results[0].Street.CECHA != "al." {
+		t.Fatalf("expected the al. record, got %+v", results[0].Street)
+	}
+}
+
+func TestConditionMatchesStreetRequiresAllSetFields(t *testing.T) {
+	st := StreetRecord{CECHA: "ul.", NAZWA1: "Chopina"}
+	c := query.Condition{StreetPrefix: "Chop", Cecha: "al."}
+	if conditionMatchesStreet(c, st) {
+		t.Fatal("expected false: CECHA doesn't match even though StreetPrefix does")
+	}
+
+	c.Cecha = "ul."
+	if !conditionMatchesStreet(c, st) {
+		t.Fatal("expected true: both fields match")
+	}
+}
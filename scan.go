@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// scanPool is a persistent, process-wide worker pool used to shard large
+// linear scans (currently GetGMIForStreet) across GOMAXPROCS goroutines
+// instead of spawning fresh ones per request.
+type scanPool struct {
+	jobs chan func()
+}
+
+// newScanPool starts n persistent workers pulling jobs off an internal
+// channel. It is never stopped: one is created at program startup and
+// lives for the process's lifetime.
+func newScanPool(n int) *scanPool {
+	p := &scanPool{jobs: make(chan func(), n)}
+	for i := 0; i < n; i++ {
+		go func() {
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+var globalScanPool = newScanPool(runtime.GOMAXPROCS(0))
+
+// scanShards splits [0, n) into up to GOMAXPROCS contiguous shards and runs
+// fn on each via the persistent worker pool, waiting for every shard to
+// finish before returning. fn is expected to check ctx between batches of
+// work (e.g. via scanCancelled) so a cancelled context stops a shard early
+// instead of running it to completion.
+func scanShards(ctx context.Context, n int, fn func(ctx context.Context, lo, hi int)) {
+	if n == 0 {
+		return
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	shard := (n + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += shard {
+		hi := lo + shard
+		if hi > n {
+			hi = n
+		}
+		lo, hi := lo, hi
+		wg.Add(1)
+		globalScanPool.jobs <- func() {
+			defer wg.Done()
+			fn(ctx, lo, hi)
+		}
+	}
+	wg.Wait()
+}
+
+// scanBatch is how often a sharded scan rechecks ctx for cancellation.
+const scanBatch = 512
+
+// scanCancelled reports whether ctx has been cancelled, checked every
+// scanBatch records so the check itself doesn't dominate a tight scan loop.
+func scanCancelled(ctx context.Context, i int) bool {
+	if i%scanBatch != 0 {
+		return false
+	}
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
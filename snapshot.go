@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// snapshotVersion is bumped whenever the on-disk snapshot format changes,
+// so LoadSnapshot can refuse to decode a layout it doesn't understand
+// instead of panicking partway through gob.Decode.
+const snapshotVersion = 1
+
+// fingerprintSampleBytes is how much of the start and end of a source CSV
+// is hashed to build its fingerprint. Hashing the whole 300k-row file on
+// every startup would cost almost as much as just re-parsing it; sampling
+// the ends plus the cheap size/mtime check is enough to catch a changed or
+// re-downloaded file in practice.
+const fingerprintSampleBytes = 64 * 1024
+
+// snapshotFingerprint identifies the exact ULIC/SIMC CSV pair a snapshot
+// was built from. LoadSnapshot recomputes this from the current files and
+// rejects the snapshot on any mismatch, falling through to LoadCSV/
+// LoadCitiesCSV.
+type snapshotFingerprint struct {
+	StreetsSize    int64
+	StreetsModTime int64
+	StreetsSum     [32]byte
+	CitiesSize     int64
+	CitiesModTime  int64
+	CitiesSum      [32]byte
+}
+
+type snapshotHeader struct {
+	Version     int
+	Fingerprint snapshotFingerprint
+}
+
+// snapshotPayload is the gob-encoded body following the header. Indexes
+// (trie, inverted index, BK-tree) aren't serialized: they're cheap to
+// rebuild from the records and rebuilding avoids having to keep a second,
+// index-specific format in sync with this one.
+type snapshotPayload struct {
+	Streets []StreetRecord
+	Cities  []CityRecord
+}
+
+// fingerprintFile hashes the first and last fingerprintSampleBytes of path
+// along with its size and modification time.
+func fingerprintFile(path string) (size int64, modTime int64, sum [32]byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, sum, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, sum, err
+	}
+
+	h := sha256.New()
+	head := make([]byte, fingerprintSampleBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, 0, sum, err
+	}
+	h.Write(head[:n])
+
+	if info.Size() > int64(fingerprintSampleBytes) {
+		if _, err := f.Seek(-int64(fingerprintSampleBytes), io.SeekEnd); err == nil {
+			tail := make([]byte, fingerprintSampleBytes)
+			tn, err := io.ReadFull(f, tail)
+			if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+				return 0, 0, sum, err
+			}
+			h.Write(tail[:tn])
+		}
+	}
+
+	copy(sum[:], h.Sum(nil))
+	return info.Size(), info.ModTime().UnixNano(), sum, nil
+}
+
+func buildSnapshotFingerprint(streetsPath, citiesPath string) (snapshotFingerprint, error) {
+	var fp snapshotFingerprint
+	var err error
+	fp.StreetsSize, fp.StreetsModTime, fp.StreetsSum, err = fingerprintFile(streetsPath)
+	if err != nil {
+		return fp, fmt.Errorf("fingerprint %s: %w", streetsPath, err)
+	}
+	fp.CitiesSize, fp.CitiesModTime, fp.CitiesSum, err = fingerprintFile(citiesPath)
+	if err != nil {
+		return fp, fmt.Errorf("fingerprint %s: %w", citiesPath, err)
+	}
+	return fp, nil
+}
+
+// SaveSnapshot writes s.streets and s.cities to path in gob format,
+// alongside a fingerprint of streetsPath and citiesPath so a later
+// LoadSnapshot can tell whether those source CSVs have changed since. It
+// writes to a temporary file and renames it into place so a crash or
+// concurrent LoadSnapshot never sees a half-written snapshot.
+func (s *AutocompleteService) SaveSnapshot(path, streetsPath, citiesPath string) error {
+	fp, err := buildSnapshotFingerprint(streetsPath, citiesPath)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+
+	s.mu.RLock()
+	header := snapshotHeader{Version: snapshotVersion, Fingerprint: fp}
+	payload := snapshotPayload{Streets: s.streets, Cities: s.cities}
+	encErr := enc.Encode(header)
+	if encErr == nil {
+		encErr = enc.Encode(payload)
+	}
+	s.mu.RUnlock()
+
+	if encErr != nil {
+		f.Close()
+		return fmt.Errorf("encode snapshot: %w", encErr)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("flush snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadSnapshot reads a snapshot previously written by SaveSnapshot. It
+// returns (true, nil) and replaces s.streets/s.cities (rebuilding both
+// indexes) if path exists, was written by this snapshotVersion, and its
+// recorded fingerprint matches streetsPath/citiesPath as they are now.
+// Otherwise it returns (false, nil) without touching s, so the caller can
+// fall through to LoadCSV/LoadCitiesCSV and call SaveSnapshot afterwards.
+func (s *AutocompleteService) LoadSnapshot(path, streetsPath, citiesPath string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return false, fmt.Errorf("decode snapshot header: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return false, nil
+	}
+
+	fp, err := buildSnapshotFingerprint(streetsPath, citiesPath)
+	if err != nil {
+		return false, err
+	}
+	if fp != header.Fingerprint {
+		return false, nil
+	}
+
+	var payload snapshotPayload
+	if err := dec.Decode(&payload); err != nil {
+		return false, fmt.Errorf("decode snapshot payload: %w", err)
+	}
+
+	s.mu.Lock()
+	s.streets = payload.Streets
+	s.cities = payload.Cities
+	s.buildStreetIndex()
+	s.buildCityIndex()
+	s.mu.Unlock()
+
+	return true, nil
+}
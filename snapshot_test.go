@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestCSVs(t *testing.T) (streetsPath, citiesPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	streetsPath = filepath.Join(dir, "streets.csv")
+	citiesPath = filepath.Join(dir, "cities.csv")
+
+	streets := "WOJ;POW;GMI;RODZ_GMI;SYM;SYM_UL;CECHA;NAZWA_1;NAZWA_2;\n" +
+		"02;01;01;1;100;1;ul.;Chopina;;\n"
+	cities := "WOJ;POW;GMI;RODZ_GMI;RM;MZ;NAZWA;SYM;SYMPOD;\n" +
+		"02;01;01;1;1;0;Warszawa;1;1;\n"
+
+	if err := os.WriteFile(streetsPath, []byte(streets), 0o644); err != nil {
+		t.Fatalf("write streets fixture: %v", err)
+	}
+	if err := os.WriteFile(citiesPath, []byte(cities), 0o644); err != nil {
+		t.Fatalf("write cities fixture: %v", err)
+	}
+	return streetsPath, citiesPath
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	streetsPath, citiesPath := writeTestCSVs(t)
+
+	s := NewAutocompleteService()
+	if err := s.LoadCSV(streetsPath); err != nil {
+		t.Fatalf("LoadCSV: %v", err)
+	}
+	if err := s.LoadCitiesCSV(citiesPath); err != nil {
+		t.Fatalf("LoadCitiesCSV: %v", err)
+	}
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.gob")
+	if err := s.SaveSnapshot(snapshotPath, streetsPath, citiesPath); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	restored := NewAutocompleteService()
+	ok, err := restored.LoadSnapshot(snapshotPath, streetsPath, citiesPath)
+	if err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected LoadSnapshot to report a hit")
+	}
+	if len(restored.streets) != len(s.streets) || len(restored.cities) != len(s.cities) {
+		t.Fatalf("restored record counts don't match: streets %d/%d, cities %d/%d",
+			len(restored.streets), len(s.streets), len(restored.cities), len(s.cities))
+	}
+	if restored.streets[0].NAZWA1 != s.streets[0].NAZWA1 {
+		t.Fatalf("restored street data doesn't match: got %+v, want %+v", restored.streets[0], s.streets[0])
+	}
+
+	// The indexes must have been rebuilt, not merely left nil.
+	results, _ := matchIndex(restored.streetIndex, foldName("Chopina"), ModeContains)
+	if len(results) != 1 {
+		t.Fatalf("expected the rebuilt street index to find Chopina, got %d hits", len(results))
+	}
+}
+
+func TestLoadSnapshotFingerprintMismatchFallsThrough(t *testing.T) {
+	streetsPath, citiesPath := writeTestCSVs(t)
+
+	s := NewAutocompleteService()
+	if err := s.LoadCSV(streetsPath); err != nil {
+		t.Fatalf("LoadCSV: %v", err)
+	}
+	if err := s.LoadCitiesCSV(citiesPath); err != nil {
+		t.Fatalf("LoadCitiesCSV: %v", err)
+	}
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.gob")
+	if err := s.SaveSnapshot(snapshotPath, streetsPath, citiesPath); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	// Mutate the source CSV after the snapshot was taken, so its fingerprint
+	// no longer matches.
+	extra := "02;01;01;1;100;2;ul.;Polna;;\n"
+	f, err := os.OpenFile(streetsPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open for append: %v", err)
+	}
+	if _, err := f.WriteString(extra); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	f.Close()
+
+	restored := NewAutocompleteService()
+	ok, err := restored.LoadSnapshot(snapshotPath, streetsPath, citiesPath)
+	if err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if ok {
+		t.Fatal("expected LoadSnapshot to reject a stale snapshot after the source CSV changed")
+	}
+	if len(restored.streets) != 0 {
+		t.Fatalf("expected restored service to be untouched, got %d streets", len(restored.streets))
+	}
+}
+
+func TestLoadSnapshotVersionMismatchFallsThrough(t *testing.T) {
+	streetsPath, citiesPath := writeTestCSVs(t)
+
+	fp, err := buildSnapshotFingerprint(streetsPath, citiesPath)
+	if err != nil {
+		t.Fatalf("buildSnapshotFingerprint: %v", err)
+	}
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.gob")
+	f, err := os.Create(snapshotPath)
+	if err != nil {
+		t.Fatalf("create snapshot: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion + 1, Fingerprint: fp}); err != nil {
+		t.Fatalf("encode header: %v", err)
+	}
+	if err := enc.Encode(snapshotPayload{}); err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	f.Close()
+
+	restored := NewAutocompleteService()
+	ok, err := restored.LoadSnapshot(snapshotPath, streetsPath, citiesPath)
+	if err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if ok {
+		t.Fatal("expected LoadSnapshot to reject a snapshot written by a newer version")
+	}
+}
+
+func TestLoadSnapshotMissingFileIsNotAnError(t *testing.T) {
+	streetsPath, citiesPath := writeTestCSVs(t)
+	s := NewAutocompleteService()
+	ok, err := s.LoadSnapshot(filepath.Join(t.TempDir(), "does-not-exist.gob"), streetsPath, citiesPath)
+	if err != nil {
+		t.Fatalf("expected a missing snapshot file to be a non-error miss, got %v", err)
+	}
+	if ok {
+		t.Fatal("expected ok=false for a missing snapshot file")
+	}
+}
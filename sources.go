@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// readerSizeHint returns r's byte length when it's cheap to know (an
+// *os.File, *bytes.Reader, or *strings.Reader), or 0 otherwise. Used only
+// to size a preallocation guess; parsing doesn't depend on it.
+func readerSizeHint(r io.Reader) int {
+	switch v := r.(type) {
+	case *os.File:
+		if info, err := v.Stat(); err == nil {
+			return int(info.Size())
+		}
+	case *bytes.Reader:
+		return v.Len()
+	case *strings.Reader:
+		return v.Len()
+	}
+	return 0
+}
+
+// sizeHinted is implemented by StreetSource/CitySource backends that know
+// roughly how many records they're about to produce. Load/LoadCities use
+// it, via a type assertion, to preallocate their result slice instead of
+// growing it one append at a time.
+type sizeHinted interface {
+	recordCapHint() int
+}
+
+// StreetSource iterates StreetRecords from some backing store: a
+// semicolon-delimited ULIC file, a proper CSV feed, a JSON-lines export, or
+// an in-memory fixture. Next returns io.EOF once exhausted, matching the
+// usual Go iterator convention (e.g. bufio.Reader.ReadString).
+type StreetSource interface {
+	Next() (StreetRecord, error)
+}
+
+// CitySource is StreetSource for SIMC localities.
+type CitySource interface {
+	Next() (CityRecord, error)
+}
+
+// streetResult and cityResult carry one parsed record or parse error across
+// the semicolon sources' worker-pool channel.
+type streetResult struct {
+	rec StreetRecord
+	err error
+}
+
+type cityResult struct {
+	rec CityRecord
+	err error
+}
+
+// semicolonStreetSource is the current ULIC parser (splitFields +
+// streetFromFields), fanned out across a worker pool via streamLines so
+// that switching LoadCSV onto the StreetSource interface costs none of the
+// concurrency chunk1-2 added.
+type semicolonStreetSource struct {
+	scanner *bufio.Scanner
+	results <-chan streetResult
+	errs    parseErrorRing
+	capHint int
+}
+
+// NewSemicolonStreetSource streams r as a semicolon-delimited ULIC file.
+func NewSemicolonStreetSource(r io.Reader, opts LoaderOptions) StreetSource {
+	opts = opts.withDefaults()
+	scanner, jobs := streamLines(r, opts)
+
+	src := &semicolonStreetSource{
+		capHint: estimateRecordCapacity(readerSizeHint(r), averageStreetRecordBytes),
+	}
+
+	results := make(chan streetResult, opts.Workers*4)
+	var wg sync.WaitGroup
+	wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				rec, issues, err := parseStreetLine(job.raw)
+				if err != nil {
+					results <- streetResult{err: LineError{Line: job.num, Raw: job.raw, Err: err}}
+					continue
+				}
+				for _, issue := range issues {
+					src.errs.add(ParseError{Line: job.num, Field: issue.Field, Raw: issue.Raw, Err: issue.Err})
+				}
+				results <- streetResult{rec: rec}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	src.scanner = scanner
+	src.results = results
+	return src
+}
+
+func (s *semicolonStreetSource) Next() (StreetRecord, error) {
+	res, ok := <-s.results
+	if !ok {
+		if err := s.scanner.Err(); err != nil {
+			return StreetRecord{}, err
+		}
+		return StreetRecord{}, io.EOF
+	}
+	return res.rec, res.err
+}
+
+func (s *semicolonStreetSource) recordCapHint() int { return s.capHint }
+
+func (s *semicolonStreetSource) parseErrors() []ParseError { return s.errs.snapshot() }
+
+// semicolonCitySource is semicolonStreetSource for SIMC rows.
+type semicolonCitySource struct {
+	scanner *bufio.Scanner
+	results <-chan cityResult
+	errs    parseErrorRing
+	capHint int
+}
+
+// NewSemicolonCitySource streams r as a semicolon-delimited SIMC file.
+func NewSemicolonCitySource(r io.Reader, opts LoaderOptions) CitySource {
+	opts = opts.withDefaults()
+	scanner, jobs := streamLines(r, opts)
+
+	src := &semicolonCitySource{
+		capHint: estimateRecordCapacity(readerSizeHint(r), averageCityRecordBytes),
+	}
+
+	results := make(chan cityResult, opts.Workers*4)
+	var wg sync.WaitGroup
+	wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				rec, issues, err := parseCityLine(job.raw)
+				if err != nil {
+					results <- cityResult{err: LineError{Line: job.num, Raw: job.raw, Err: err}}
+					continue
+				}
+				for _, issue := range issues {
+					src.errs.add(ParseError{Line: job.num, Field: issue.Field, Raw: issue.Raw, Err: issue.Err})
+				}
+				results <- cityResult{rec: rec}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	src.scanner = scanner
+	src.results = results
+	return src
+}
+
+func (s *semicolonCitySource) Next() (CityRecord, error) {
+	res, ok := <-s.results
+	if !ok {
+		if err := s.scanner.Err(); err != nil {
+			return CityRecord{}, err
+		}
+		return CityRecord{}, io.EOF
+	}
+	return res.rec, res.err
+}
+
+func (s *semicolonCitySource) recordCapHint() int { return s.capHint }
+
+func (s *semicolonCitySource) parseErrors() []ParseError { return s.errs.snapshot() }
+
+// csvStreetSource parses with encoding/csv instead of splitFields, for
+// feeds that properly quote fields containing semicolons (the hand-rolled
+// semicolon source can't tell those apart from field separators).
+type csvStreetSource struct {
+	r       *csv.Reader
+	lineNum int
+	started bool
+	errs    parseErrorRing
+	capHint int
+}
+
+// NewCSVStreetSource wraps r with encoding/csv, configured for TERYT's
+// semicolon-separated, loosely-quoted dialect (LazyQuotes tolerates the
+// unescaped quotes the plain semicolon parser has to work around). The
+// header row is skipped automatically.
+func NewCSVStreetSource(r io.Reader) StreetSource {
+	cr := csv.NewReader(r)
+	cr.Comma = ';'
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+	return &csvStreetSource{r: cr, capHint: estimateRecordCapacity(readerSizeHint(r), averageStreetRecordBytes)}
+}
+
+func (s *csvStreetSource) Next() (StreetRecord, error) {
+	if !s.started {
+		s.started = true
+		if _, err := s.r.Read(); err != nil {
+			return StreetRecord{}, err
+		}
+		s.lineNum++
+	}
+
+	fields, err := s.r.Read()
+	if err != nil {
+		return StreetRecord{}, err
+	}
+	s.lineNum++
+
+	rec, issues, err := streetFromFields(fields)
+	if err != nil {
+		return StreetRecord{}, LineError{Line: s.lineNum, Raw: strings.Join(fields, ";"), Err: err}
+	}
+	for _, issue := range issues {
+		s.errs.add(ParseError{Line: s.lineNum, Field: issue.Field, Raw: issue.Raw, Err: issue.Err})
+	}
+	return rec, nil
+}
+
+func (s *csvStreetSource) recordCapHint() int { return s.capHint }
+
+func (s *csvStreetSource) parseErrors() []ParseError { return s.errs.snapshot() }
+
+// csvCitySource is csvStreetSource for SIMC rows.
+type csvCitySource struct {
+	r       *csv.Reader
+	lineNum int
+	started bool
+	errs    parseErrorRing
+	capHint int
+}
+
+// NewCSVCitySource is NewCSVStreetSource for SIMC files.
+func NewCSVCitySource(r io.Reader) CitySource {
+	cr := csv.NewReader(r)
+	cr.Comma = ';'
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+	return &csvCitySource{r: cr, capHint: estimateRecordCapacity(readerSizeHint(r), averageCityRecordBytes)}
+}
+
+func (s *csvCitySource) Next() (CityRecord, error) {
+	if !s.started {
+		s.started = true
+		if _, err := s.r.Read(); err != nil {
+			return CityRecord{}, err
+		}
+		s.lineNum++
+	}
+
+	fields, err := s.r.Read()
+	if err != nil {
+		return CityRecord{}, err
+	}
+	s.lineNum++
+
+	rec, issues, err := cityFromFields(fields)
+	if err != nil {
+		return CityRecord{}, LineError{Line: s.lineNum, Raw: strings.Join(fields, ";"), Err: err}
+	}
+	for _, issue := range issues {
+		s.errs.add(ParseError{Line: s.lineNum, Field: issue.Field, Raw: issue.Raw, Err: issue.Err})
+	}
+	return rec, nil
+}
+
+func (s *csvCitySource) recordCapHint() int { return s.capHint }
+
+func (s *csvCitySource) parseErrors() []ParseError { return s.errs.snapshot() }
+
+// jsonLinesStreetSource reads one JSON-encoded StreetRecord per line, for
+// feeds that export newline-delimited JSON instead of CSV (e.g. a
+// preprocessed columnar snapshot).
+type jsonLinesStreetSource struct {
+	scanner *bufio.Scanner
+	lineNum int
+}
+
+// NewJSONLinesStreetSource wraps r as a JSON-lines stream of StreetRecords.
+func NewJSONLinesStreetSource(r io.Reader) StreetSource {
+	return &jsonLinesStreetSource{scanner: bufio.NewScanner(r)}
+}
+
+func (s *jsonLinesStreetSource) Next() (StreetRecord, error) {
+	for s.scanner.Scan() {
+		s.lineNum++
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec StreetRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return StreetRecord{}, LineError{Line: s.lineNum, Raw: line, Err: err}
+		}
+		if rec.FullName == "" {
+			if rec.NAZWA2 != "" {
+				rec.FullName = rec.CECHA + " " + rec.NAZWA1 + " " + rec.NAZWA2
+			} else {
+				rec.FullName = rec.CECHA + " " + rec.NAZWA1
+			}
+		}
+		return rec, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return StreetRecord{}, err
+	}
+	return StreetRecord{}, io.EOF
+}
+
+// jsonLinesCitySource is jsonLinesStreetSource for SIMC-shaped records.
+type jsonLinesCitySource struct {
+	scanner *bufio.Scanner
+	lineNum int
+}
+
+// NewJSONLinesCitySource wraps r as a JSON-lines stream of CityRecords.
+func NewJSONLinesCitySource(r io.Reader) CitySource {
+	return &jsonLinesCitySource{scanner: bufio.NewScanner(r)}
+}
+
+func (s *jsonLinesCitySource) Next() (CityRecord, error) {
+	for s.scanner.Scan() {
+		s.lineNum++
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec CityRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return CityRecord{}, LineError{Line: s.lineNum, Raw: line, Err: err}
+		}
+		return rec, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return CityRecord{}, err
+	}
+	return CityRecord{}, io.EOF
+}
+
+// fixtureStreetSource serves a preloaded, in-memory slice of records. Handy
+// for tests that want to exercise AutocompleteService.Load without writing
+// a CSV fixture to disk.
+type fixtureStreetSource struct {
+	records []StreetRecord
+	i       int
+}
+
+// NewFixtureStreetSource returns a StreetSource that yields records in
+// order, then io.EOF.
+func NewFixtureStreetSource(records []StreetRecord) StreetSource {
+	return &fixtureStreetSource{records: records}
+}
+
+func (s *fixtureStreetSource) Next() (StreetRecord, error) {
+	if s.i >= len(s.records) {
+		return StreetRecord{}, io.EOF
+	}
+	rec := s.records[s.i]
+	s.i++
+	return rec, nil
+}
+
+// fixtureCitySource is fixtureStreetSource for CityRecords.
+type fixtureCitySource struct {
+	records []CityRecord
+	i       int
+}
+
+// NewFixtureCitySource returns a CitySource that yields records in order,
+// then io.EOF.
+func NewFixtureCitySource(records []CityRecord) CitySource {
+	return &fixtureCitySource{records: records}
+}
+
+func (s *fixtureCitySource) Next() (CityRecord, error) {
+	if s.i >= len(s.records) {
+		return CityRecord{}, io.EOF
+	}
+	rec := s.records[s.i]
+	s.i++
+	return rec, nil
+}
+
+// parseErrorSource is implemented by StreetSource/CitySource backends that
+// track per-field parse errors (currently the semicolon and CSV sources).
+// Load/LoadCities check for it via a type assertion and merge its errors
+// into the service's own bounded ring once the source is drained.
+type parseErrorSource interface {
+	parseErrors() []ParseError
+}
+
+// Load drains src, replacing s.streets and rebuilding the street index. It
+// is the single entry point every StreetSource feeds through — LoadCSV,
+// LoadStreetsFromURL, and any future TERYT SOAP/columnar backend built on
+// top of a new StreetSource implementation. ctx is checked between records
+// so a slow or endless source can still be cancelled. A LineError from
+// src.Next() is recorded in the returned LoadReport and the row is
+// skipped; any other error aborts the load immediately, leaving s.streets
+// untouched. If src reports a byte-length hint, the result slice is
+// preallocated accordingly instead of growing one append at a time.
+func (s *AutocompleteService) Load(ctx context.Context, src StreetSource) (LoadReport, error) {
+	var streets []StreetRecord
+	if sh, ok := src.(sizeHinted); ok {
+		if n := sh.recordCapHint(); n > 0 {
+			streets = make([]StreetRecord, 0, n)
+		}
+	}
+	var report LoadReport
+
+	for i := 0; ; i++ {
+		if scanCancelled(ctx, i) {
+			return report, ctx.Err()
+		}
+		rec, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var lerr LineError
+		if errors.As(err, &lerr) {
+			report.Skipped++
+			report.Errors = append(report.Errors, lerr)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		streets = append(streets, rec)
+		report.Loaded++
+	}
+
+	if pes, ok := src.(parseErrorSource); ok {
+		for _, pe := range pes.parseErrors() {
+			s.lastLoadErrors.add(pe)
+		}
+	}
+
+	s.mu.Lock()
+	s.streets = streets
+	s.buildStreetIndex()
+	s.mu.Unlock()
+
+	return report, nil
+}
+
+// LoadCities is Load for CitySource, replacing s.cities and rebuilding the
+// city index.
+func (s *AutocompleteService) LoadCities(ctx context.Context, src CitySource) (LoadReport, error) {
+	var cities []CityRecord
+	if sh, ok := src.(sizeHinted); ok {
+		if n := sh.recordCapHint(); n > 0 {
+			cities = make([]CityRecord, 0, n)
+		}
+	}
+	var report LoadReport
+
+	for i := 0; ; i++ {
+		if scanCancelled(ctx, i) {
+			return report, ctx.Err()
+		}
+		rec, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var lerr LineError
+		if errors.As(err, &lerr) {
+			report.Skipped++
+			report.Errors = append(report.Errors, lerr)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		cities = append(cities, rec)
+		report.Loaded++
+	}
+
+	if pes, ok := src.(parseErrorSource); ok {
+		for _, pe := range pes.parseErrors() {
+			s.lastLoadErrors.add(pe)
+		}
+	}
+
+	s.mu.Lock()
+	s.cities = cities
+	s.buildCityIndex()
+	s.mu.Unlock()
+
+	return report, nil
+}
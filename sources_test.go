@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadFromFixtureStreetSource(t *testing.T) {
+	s := NewAutocompleteService()
+	records := []StreetRecord{
+		{WOJ: 2, POW: 1, GMI: 1, SYM: 100, SYMUL: 1, CECHA: "ul.", NAZWA1: "Chopina", FullName: "ul. Chopina"},
+		{WOJ: 2, POW: 1, GMI: 1, SYM: 100, SYMUL: 2, CECHA: "ul.", NAZWA1: "Polna", FullName: "ul. Polna"},
+	}
+
+	report, err := s.Load(context.Background(), NewFixtureStreetSource(records))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if report.Loaded != 2 || report.Skipped != 0 {
+		t.Fatalf("expected 2 loaded, 0 skipped, got %+v", report)
+	}
+	if len(s.streets) != 2 {
+		t.Fatalf("expected 2 streets in service, got %d", len(s.streets))
+	}
+}
+
+func TestLoadCitiesFromFixtureCitySource(t *testing.T) {
+	s := NewAutocompleteService()
+	records := []CityRecord{
+		{WOJ: 2, POW: 1, GMI: 1, NAZWA: "Warszawa", SYM: 1},
+		{WOJ: 2, POW: 1, GMI: 1, NAZWA: "Kraków", SYM: 2},
+	}
+
+	report, err := s.LoadCities(context.Background(), NewFixtureCitySource(records))
+	if err != nil {
+		t.Fatalf("LoadCities: %v", err)
+	}
+	if report.Loaded != 2 || report.Skipped != 0 {
+		t.Fatalf("expected 2 loaded, 0 skipped, got %+v", report)
+	}
+	if len(s.cities) != 2 {
+		t.Fatalf("expected 2 cities in service, got %d", len(s.cities))
+	}
+}
+
+func TestLoadCSVStreetSourceSkipsMalformedRows(t *testing.T) {
+	s := NewAutocompleteService()
+	csv := "WOJ;POW;GMI;RODZ_GMI;SYM;SYM_UL;CECHA;NAZWA_1;NAZWA_2;\n" +
+		"02;01;01;1;100;1;ul.;Chopina;;\n" +
+		"02;01;01;1;100;2;ul.;;;\n" // empty NAZWA_1 is fatal for this row
+
+	report, err := s.Load(context.Background(), NewCSVStreetSource(strings.NewReader(csv)))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if report.Loaded != 1 || report.Skipped != 1 {
+		t.Fatalf("expected 1 loaded, 1 skipped, got %+v", report)
+	}
+}
+
+func TestLoadCSVWithOptionsStrictModeFailsOnFirstMalformedRow(t *testing.T) {
+	s := NewAutocompleteService()
+
+	dir := t.TempDir()
+	path := dir + "/streets.csv"
+	csv := "WOJ;POW;GMI;RODZ_GMI;SYM;SYM_UL;CECHA;NAZWA_1;NAZWA_2;\n" +
+		"02;01;01;1;100;1;ul.;;;\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	opts := DefaultLoaderOptions()
+	opts.StrictMode = true
+	if _, err := s.LoadCSVWithOptions(path, opts); err == nil {
+		t.Fatal("expected StrictMode to return an error for a malformed row")
+	}
+
+	opts.StrictMode = false
+	report, err := s.LoadCSVWithOptions(path, opts)
+	if err != nil {
+		t.Fatalf("non-strict LoadCSVWithOptions: %v", err)
+	}
+	if report.Loaded != 0 || report.Skipped != 1 {
+		t.Fatalf("expected 0 loaded, 1 skipped, got %+v", report)
+	}
+}
+
+func TestLoadJSONLinesStreetSource(t *testing.T) {
+	s := NewAutocompleteService()
+	lines := `{"woj":2,"pow":1,"gmi":1,"sym":100,"sym_ul":1,"cecha":"ul.","nazwa_1":"Chopina"}
+{"woj":2,"pow":1,"gmi":1,"sym":100,"sym_ul":2,"cecha":"ul.","nazwa_1":"Polna"}
+`
+	report, err := s.Load(context.Background(), NewJSONLinesStreetSource(strings.NewReader(lines)))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if report.Loaded != 2 {
+		t.Fatalf("expected 2 loaded, got %+v", report)
+	}
+	if s.streets[0].FullName != "ul. Chopina" {
+		t.Fatalf("expected FullName to be derived when absent, got %q", s.streets[0].FullName)
+	}
+}
+
+func TestLoadCitiesJSONLinesCitySource(t *testing.T) {
+	s := NewAutocompleteService()
+	lines := `{"woj":2,"pow":1,"gmi":1,"nazwa":"Warszawa","sym":1}
+{"woj":2,"pow":1,"gmi":1,"nazwa":"Kraków","sym":2}
+`
+	report, err := s.LoadCities(context.Background(), NewJSONLinesCitySource(strings.NewReader(lines)))
+	if err != nil {
+		t.Fatalf("LoadCities: %v", err)
+	}
+	if report.Loaded != 2 {
+		t.Fatalf("expected 2 loaded, got %+v", report)
+	}
+}
@@ -0,0 +1,140 @@
+// Package transit ingests GTFS (General Transit Feed Specification) feeds
+// and geocodes their stops against TERYT locality names, so the
+// autocomplete service can answer "what transit stops serve this
+// locality?" alongside its street/city search.
+//
+// GTFS feeds don't carry a locality reference on each stop, so geocoding
+// here is a text match between a stop's name and a locality's name rather
+// than a true geospatial lookup; callers that need precision should scope
+// a feed to a single województwo via FeedConfig.WOJHint.
+package transit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Agency is one GTFS agency.txt row.
+type Agency struct {
+	ID   string
+	Name string
+}
+
+// Route is one GTFS routes.txt row.
+type Route struct {
+	ID        string
+	AgencyID  string
+	ShortName string
+	LongName  string
+}
+
+// Stop is one GTFS stops.txt row.
+type Stop struct {
+	ID   string
+	Name string
+	Lat  float64
+	Lon  float64
+}
+
+// Feed is one parsed GTFS feed.
+type Feed struct {
+	Agencies []Agency
+	Routes   []Route
+	Stops    []Stop
+}
+
+// LoadFeed parses the agency.txt, routes.txt, and stops.txt files in dir
+// into a Feed. Any of the three may be absent, since not every feed
+// publishes every file; a missing file simply yields no rows for that
+// part of the feed.
+func LoadFeed(dir string) (*Feed, error) {
+	feed := &Feed{}
+
+	agencyRows, err := readCSVRowsOptional(filepath.Join(dir, "agency.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("read agency.txt: %w", err)
+	}
+	for _, row := range agencyRows {
+		feed.Agencies = append(feed.Agencies, Agency{ID: row["agency_id"], Name: row["agency_name"]})
+	}
+
+	routeRows, err := readCSVRowsOptional(filepath.Join(dir, "routes.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("read routes.txt: %w", err)
+	}
+	for _, row := range routeRows {
+		feed.Routes = append(feed.Routes, Route{
+			ID:        row["route_id"],
+			AgencyID:  row["agency_id"],
+			ShortName: row["route_short_name"],
+			LongName:  row["route_long_name"],
+		})
+	}
+
+	stopRows, err := readCSVRowsOptional(filepath.Join(dir, "stops.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("read stops.txt: %w", err)
+	}
+	for _, row := range stopRows {
+		lat, _ := strconv.ParseFloat(row["stop_lat"], 64)
+		lon, _ := strconv.ParseFloat(row["stop_lon"], 64)
+		feed.Stops = append(feed.Stops, Stop{ID: row["stop_id"], Name: row["stop_name"], Lat: lat, Lon: lon})
+	}
+
+	return feed, nil
+}
+
+// readCSVRowsOptional is readCSVRows, except a missing file yields no rows
+// instead of an error.
+func readCSVRowsOptional(path string) ([]map[string]string, error) {
+	rows, err := readCSVRows(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return rows, err
+}
+
+// readCSVRows reads a standard (comma-separated, quoted) GTFS CSV file and
+// returns each row as a column-name-to-value map, keyed by the header row.
+func readCSVRows(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.TrimLeadingSpace = true
+	// GTFS files in the wild are inconsistent about trailing columns;
+	// don't fail the whole feed over a short row.
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				row[col] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,62 @@
+package transit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeGTFSFeed(t *testing.T, dir string) {
+	t.Helper()
+	files := map[string]string{
+		"agency.txt": "agency_id,agency_name\n1,ZTM Warszawa\n",
+		"routes.txt": "route_id,agency_id,route_short_name,route_long_name\n1,1,180,Linia 180\n",
+		"stops.txt":  "stop_id,stop_name,stop_lat,stop_lon\n1,Warszawa Centrum,52.2297,21.0122\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadFeedParsesAllThreeFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeGTFSFeed(t, dir)
+
+	feed, err := LoadFeed(dir)
+	if err != nil {
+		t.Fatalf("LoadFeed: %v", err)
+	}
+	if len(feed.Agencies) != 1 || feed.Agencies[0].Name != "ZTM Warszawa" {
+		t.Fatalf("unexpected agencies: %+v", feed.Agencies)
+	}
+	if len(feed.Routes) != 1 || feed.Routes[0].ShortName != "180" {
+		t.Fatalf("unexpected routes: %+v", feed.Routes)
+	}
+	if len(feed.Stops) != 1 || feed.Stops[0].Name != "Warszawa Centrum" {
+		t.Fatalf("unexpected stops: %+v", feed.Stops)
+	}
+	if feed.Stops[0].Lat != 52.2297 || feed.Stops[0].Lon != 21.0122 {
+		t.Fatalf("stop coordinates not parsed: %+v", feed.Stops[0])
+	}
+}
+
+func TestLoadFeedToleratesMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	// Only stops.txt present; agency.txt and routes.txt are absent.
+	if err := os.WriteFile(filepath.Join(dir, "stops.txt"), []byte("stop_id,stop_name,stop_lat,stop_lon\n1,Centrum,0,0\n"), 0o644); err != nil {
+		t.Fatalf("write stops.txt: %v", err)
+	}
+
+	feed, err := LoadFeed(dir)
+	if err != nil {
+		t.Fatalf("LoadFeed: %v", err)
+	}
+	if len(feed.Agencies) != 0 || len(feed.Routes) != 0 {
+		t.Fatalf("expected no agencies/routes, got %+v / %+v", feed.Agencies, feed.Routes)
+	}
+	if len(feed.Stops) != 1 {
+		t.Fatalf("expected 1 stop, got %+v", feed.Stops)
+	}
+}
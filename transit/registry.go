@@ -0,0 +1,211 @@
+package transit
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// diacriticFolder strips Polish diacritics, mirroring the main package's
+// own foldName; duplicated here since package main can't be imported.
+var diacriticFolder = strings.NewReplacer(
+	"ł", "l", "Ł", "L",
+	"ą", "a", "Ą", "A",
+	"ę", "e", "Ę", "E",
+	"ś", "s", "Ś", "S",
+	"ż", "z", "Ż", "Z",
+	"ź", "z", "Ź", "Z",
+	"ć", "c", "Ć", "C",
+	"ń", "n", "Ń", "N",
+	"ó", "o", "Ó", "O",
+)
+
+func fold(s string) string {
+	return strings.ToLower(diacriticFolder.Replace(s))
+}
+
+// CityRef is the minimal information Registry needs about a locality to
+// geocode stops against it.
+type CityRef struct {
+	Name string
+	WOJ  int
+}
+
+// FeedConfig names one GTFS feed directory to ingest. WOJHint, when
+// nonzero, restricts geocoding to localities in that województwo, which
+// avoids false positives between towns that share a name in different
+// parts of the country.
+type FeedConfig struct {
+	Dir     string
+	WOJHint int
+}
+
+// StopSummary is one transit stop matched to a locality, along with the
+// line names of the feed it came from (GTFS doesn't link a stop to its
+// serving routes without trips.txt/stop_times.txt, so this is every route
+// in the feed rather than only the ones calling at this exact stop).
+type StopSummary struct {
+	Name  string   `json:"name"`
+	Lat   float64  `json:"lat"`
+	Lon   float64  `json:"lon"`
+	Lines []string `json:"lines,omitempty"`
+}
+
+// CitySummary is the transit_summary enrichment attached to a city
+// autocomplete result.
+type CitySummary struct {
+	StopCount int       `json:"stop_count"`
+	Agencies  []string  `json:"agencies,omitempty"`
+	Center    *GeoPoint `json:"center,omitempty"`
+}
+
+// GeoPoint is a WGS84 latitude/longitude pair.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// cityEntry is one locality's precomputed transit index. center is the
+// centroid of every matched stop's coordinates — TERYT's SIMC doesn't carry
+// a city-level coordinate of its own, so the centroid of the stops already
+// geocoded to it by name is the only anchor point Lookup's radius filter
+// has to work with.
+type cityEntry struct {
+	stops    []StopSummary
+	agencies map[string]bool
+	center   GeoPoint
+}
+
+// earthRadiusMeters is the mean Earth radius used by haversineMeters.
+const earthRadiusMeters = 6371000
+
+// haversineMeters returns the great-circle distance between two WGS84
+// points, in meters.
+func haversineMeters(a, b GeoPoint) float64 {
+	lat1, lon1 := a.Lat*math.Pi/180, a.Lon*math.Pi/180
+	lat2, lon2 := b.Lat*math.Pi/180, b.Lon*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := lon2 - lon1
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+// Registry holds every loaded GTFS feed's stops, geocoded against a set of
+// localities. It is safe for concurrent use: Load builds a fresh index and
+// swaps it in under a write lock, so a hot-reload never exposes a
+// half-built index to concurrent lookups.
+type Registry struct {
+	mu     sync.RWMutex
+	byCity map[string]*cityEntry // folded city name -> entry
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{byCity: make(map[string]*cityEntry)}
+}
+
+// Load ingests every feed in feeds and geocodes their stops against
+// cities, replacing the Registry's index. Localities with no matching
+// stop in any feed are simply absent from the index afterward.
+func (r *Registry) Load(feeds []FeedConfig, cities []CityRef) error {
+	byCity := make(map[string]*cityEntry)
+
+	for _, fc := range feeds {
+		feed, err := LoadFeed(fc.Dir)
+		if err != nil {
+			return err
+		}
+
+		lines := make([]string, 0, len(feed.Routes))
+		for _, route := range feed.Routes {
+			name := route.ShortName
+			if name == "" {
+				name = route.LongName
+			}
+			if name != "" {
+				lines = append(lines, name)
+			}
+		}
+
+		for _, city := range cities {
+			if fc.WOJHint != 0 && city.WOJ != fc.WOJHint {
+				continue
+			}
+
+			folded := fold(city.Name)
+			var matched []Stop
+			for _, stop := range feed.Stops {
+				if strings.Contains(fold(stop.Name), folded) {
+					matched = append(matched, stop)
+				}
+			}
+			if len(matched) == 0 {
+				continue
+			}
+
+			entry := byCity[folded]
+			if entry == nil {
+				entry = &cityEntry{agencies: make(map[string]bool)}
+				byCity[folded] = entry
+			}
+			for _, stop := range matched {
+				entry.stops = append(entry.stops, StopSummary{Name: stop.Name, Lat: stop.Lat, Lon: stop.Lon, Lines: lines})
+			}
+			for _, agency := range feed.Agencies {
+				entry.agencies[agency.Name] = true
+			}
+		}
+	}
+
+	for _, entry := range byCity {
+		var sumLat, sumLon float64
+		for _, stop := range entry.stops {
+			sumLat += stop.Lat
+			sumLon += stop.Lon
+		}
+		n := float64(len(entry.stops))
+		entry.center = GeoPoint{Lat: sumLat / n, Lon: sumLon / n}
+	}
+
+	r.mu.Lock()
+	r.byCity = byCity
+	r.mu.Unlock()
+	return nil
+}
+
+// Lookup returns the transit summary and matched stops for cityName, or
+// (nil, nil) if no feed's stops geocoded to it. If radiusMeters is
+// positive, only stops within that distance of the city's stop centroid
+// (CitySummary.Center) are returned; zero or negative means no radius
+// filtering, returning every matched stop.
+func (r *Registry) Lookup(cityName string, radiusMeters float64) (*CitySummary, []StopSummary) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.byCity[fold(cityName)]
+	if !ok {
+		return nil, nil
+	}
+
+	agencies := make([]string, 0, len(entry.agencies))
+	for name := range entry.agencies {
+		agencies = append(agencies, name)
+	}
+	sort.Strings(agencies)
+
+	stops := entry.stops
+	if radiusMeters > 0 {
+		filtered := make([]StopSummary, 0, len(entry.stops))
+		for _, stop := range entry.stops {
+			if haversineMeters(entry.center, GeoPoint{Lat: stop.Lat, Lon: stop.Lon}) <= radiusMeters {
+				filtered = append(filtered, stop)
+			}
+		}
+		stops = filtered
+	}
+
+	center := entry.center
+	return &CitySummary{StopCount: len(stops), Agencies: agencies, Center: &center}, stops
+}
@@ -0,0 +1,182 @@
+package transit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFeedDir(t *testing.T, agency, stops string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "agency.txt"), []byte(agency), 0o644); err != nil {
+		t.Fatalf("write agency.txt: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stops.txt"), []byte(stops), 0o644); err != nil {
+		t.Fatalf("write stops.txt: %v", err)
+	}
+	return dir
+}
+
+func TestRegistryLoadGeocodesStopsAndLookupReturnsSummary(t *testing.T) {
+	dir := writeFeedDir(t,
+		"agency_id,agency_name\n1,ZTM Warszawa\n",
+		"stop_id,stop_name,stop_lat,stop_lon\n"+
+			"1,Warszawa Centrum,52.2297,21.0122\n"+
+			"2,Warszawa Ratusz,52.2480,21.0084\n"+
+			"3,Krakow Glowny,50.0677,19.9450\n",
+	)
+
+	r := NewRegistry()
+	err := r.Load(
+		[]FeedConfig{{Dir: dir}},
+		[]CityRef{{Name: "Warszawa"}, {Name: "Krakow"}},
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	summary, stops := r.Lookup("Warszawa", 0)
+	if summary == nil {
+		t.Fatal("expected a summary for Warszawa")
+	}
+	if summary.StopCount != 2 || len(stops) != 2 {
+		t.Fatalf("expected 2 stops matched to Warszawa, got %d: %+v", summary.StopCount, stops)
+	}
+	if len(summary.Agencies) != 1 || summary.Agencies[0] != "ZTM Warszawa" {
+		t.Fatalf("unexpected agencies: %+v", summary.Agencies)
+	}
+
+	summary, stops = r.Lookup("Krakow", 0)
+	if summary == nil || summary.StopCount != 1 || len(stops) != 1 {
+		t.Fatalf("expected 1 stop matched to Krakow, got %+v / %+v", summary, stops)
+	}
+}
+
+func TestRegistryLookupUnknownCityReturnsNil(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Load(nil, nil); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	summary, stops := r.Lookup("Nieznane", 0)
+	if summary != nil || stops != nil {
+		t.Fatalf("expected (nil, nil) for a city with no matching stops, got %+v / %+v", summary, stops)
+	}
+}
+
+func TestRegistryLoadRespectsWOJHint(t *testing.T) {
+	dir := writeFeedDir(t,
+		"agency_id,agency_name\n1,Lokalny Przewoznik\n",
+		"stop_id,stop_name,stop_lat,stop_lon\n1,Springfield Centrum,0,0\n",
+	)
+
+	r := NewRegistry()
+	err := r.Load(
+		[]FeedConfig{{Dir: dir, WOJHint: 2}},
+		[]CityRef{{Name: "Springfield", WOJ: 14}},
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if summary, _ := r.Lookup("Springfield", 0); summary != nil {
+		t.Fatalf("expected the WOJHint mismatch to exclude Springfield, got %+v", summary)
+	}
+
+	err = r.Load(
+		[]FeedConfig{{Dir: dir, WOJHint: 14}},
+		[]CityRef{{Name: "Springfield", WOJ: 14}},
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if summary, _ := r.Lookup("Springfield", 0); summary == nil {
+		t.Fatal("expected a matching WOJHint to include Springfield")
+	}
+}
+
+func TestRegistryLookupRadiusFilter(t *testing.T) {
+	// Two stops close together and one far outlier, all matched to the same
+	// city. The centroid is the average of all three, so the expected
+	// in-radius set is derived via haversineMeters from that same centroid
+	// rather than hand-computed, since the centroid shifts with the mix.
+	near1 := GeoPoint{Lat: 52.2297, Lon: 21.0122}
+	near2 := GeoPoint{Lat: 52.2299, Lon: 21.0125}
+	far := GeoPoint{Lat: 50.0677, Lon: 19.9450}
+
+	dir := writeFeedDir(t,
+		"agency_id,agency_name\n1,ZTM Warszawa\n",
+		"stop_id,stop_name,stop_lat,stop_lon\n"+
+			"1,Warszawa A,52.2297,21.0122\n"+
+			"2,Warszawa B,52.2299,21.0125\n"+
+			"3,Warszawa C,50.0677,19.9450\n",
+	)
+
+	r := NewRegistry()
+	if err := r.Load([]FeedConfig{{Dir: dir}}, []CityRef{{Name: "Warszawa"}}); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	full, allStops := r.Lookup("Warszawa", 0)
+	if full == nil || full.Center == nil || len(allStops) != 3 {
+		t.Fatalf("expected all 3 stops unfiltered, got %+v / %+v", full, allStops)
+	}
+	centroid := *full.Center
+
+	dNear1 := haversineMeters(centroid, near1)
+	dNear2 := haversineMeters(centroid, near2)
+	dFar := haversineMeters(centroid, far)
+
+	if dFar <= dNear1 || dFar <= dNear2 {
+		t.Fatalf("expected the outlier to be farther from the centroid than the near pair: near1=%.0fm near2=%.0fm far=%.0fm", dNear1, dNear2, dFar)
+	}
+
+	radius := (maxOf(dNear1, dNear2) + dFar) / 2
+	filtered, stops := r.Lookup("Warszawa", radius)
+	if filtered.StopCount != 2 || len(stops) != 2 {
+		t.Fatalf("expected radius %.0fm to keep only the near pair, got %d stops: %+v", radius, filtered.StopCount, stops)
+	}
+	for _, s := range stops {
+		if s.Name == "Warszawa C" {
+			t.Fatalf("expected the outlier stop to be excluded by the radius filter, got %+v", stops)
+		}
+	}
+}
+
+func maxOf(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func TestRegistryLoadHotReloadReplacesIndex(t *testing.T) {
+	dirOld := writeFeedDir(t,
+		"agency_id,agency_name\n1,Stary Przewoznik\n",
+		"stop_id,stop_name,stop_lat,stop_lon\n1,Warszawa Stara,52.2297,21.0122\n",
+	)
+	dirNew := writeFeedDir(t,
+		"agency_id,agency_name\n1,Nowy Przewoznik\n",
+		"stop_id,stop_name,stop_lat,stop_lon\n1,Warszawa Nowa,52.2480,21.0084\n",
+	)
+
+	r := NewRegistry()
+	if err := r.Load([]FeedConfig{{Dir: dirOld}}, []CityRef{{Name: "Warszawa"}}); err != nil {
+		t.Fatalf("first Load: %v", err)
+	}
+	if summary, _ := r.Lookup("Warszawa", 0); summary == nil || summary.Agencies[0] != "Stary Przewoznik" {
+		t.Fatalf("expected the old feed's data, got %+v", summary)
+	}
+
+	if err := r.Load([]FeedConfig{{Dir: dirNew}}, []CityRef{{Name: "Warszawa"}}); err != nil {
+		t.Fatalf("second Load: %v", err)
+	}
+	summary, stops := r.Lookup("Warszawa", 0)
+	if summary == nil || summary.Agencies[0] != "Nowy Przewoznik" {
+		t.Fatalf("expected the reload to replace the old feed's data, got %+v", summary)
+	}
+	if len(stops) != 1 || stops[0].Name != "Warszawa Nowa" {
+		t.Fatalf("expected only the new feed's stop, got %+v", stops)
+	}
+}
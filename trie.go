@@ -0,0 +1,69 @@
+package main
+
+// trieNode is one node of a nameTrie, keyed by folded rune.
+type trieNode struct {
+	children map[rune]*trieNode
+	idxs     []int // record indices whose folded name ends exactly at this node
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: make(map[rune]*trieNode)}
+}
+
+// collect walks the subtree rooted at n and appends every record index found
+// under it, in insertion order.
+func (n *trieNode) collect(out []int) []int {
+	out = append(out, n.idxs...)
+	for _, child := range n.children {
+		out = child.collect(out)
+	}
+	return out
+}
+
+// nameTrie is a prefix trie over folded record names. It answers
+// prefix-autocomplete queries without scanning every record.
+//
+// Nodes are not path-compressed: a run of single-child nodes (e.g. along a
+// rare, long suffix) allocates one map[rune]*trieNode per rune rather than
+// collapsing into a single radix edge. A compressed trie would use
+// meaningfully less memory over a few hundred thousand street/city names
+// (invertedIndex.insert alone inserts every suffix of every token into one
+// of these), but prefixSearch/insert are still O(len(key)) either way, so
+// correctness and query latency are unaffected — this was a deliberate
+// space/complexity trade-off, not an oversight.
+type nameTrie struct {
+	root *trieNode
+}
+
+func newNameTrie() *nameTrie {
+	return &nameTrie{root: newTrieNode()}
+}
+
+// insert adds idx under folded, the diacritic-folded, lowercased name of the
+// record at that index.
+func (t *nameTrie) insert(folded string, idx int) {
+	node := t.root
+	for _, r := range folded {
+		child, ok := node.children[r]
+		if !ok {
+			child = newTrieNode()
+			node.children[r] = child
+		}
+		node = child
+	}
+	node.idxs = append(node.idxs, idx)
+}
+
+// prefixSearch returns every record index whose folded name starts with
+// prefix, in trie insertion order.
+func (t *nameTrie) prefixSearch(prefix string) []int {
+	node := t.root
+	for _, r := range prefix {
+		child, ok := node.children[r]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	return node.collect(nil)
+}